@@ -2,21 +2,24 @@ package airplay
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"math"
+	"net"
 	"net/http"
-	"net/url"
-	"os"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/DHowett/go-plist"
 )
 
 // A PlaybackInfo is a playback information of playing content.
+//
+// Values are decoded regardless of the value types that differ between devices,
+// so a field is left zero only when the device does not report it.
 type PlaybackInfo struct {
 	// IsReadyToPlay, if true, content is currently playing or ready to play.
 	IsReadyToPlay bool `plist:"readyToPlay"`
@@ -24,15 +27,109 @@ type PlaybackInfo struct {
 	// ReadyToPlayValue represents the information on whether content is currently playing, ready to play or not.
 	ReadyToPlayValue interface{} `plist:"readyToPlay"`
 
+	// ReadyToPlayMs represents the time taken until content became ready to play in milliseconds.
+	ReadyToPlayMs int64 `plist:"readyToPlayMs"`
+
 	// Duration represents playback duration in seconds.
 	Duration float64 `plist:"duration"`
 
 	// Position represents playback position in seconds.
 	Position float64 `plist:"position"`
+
+	// Rate represents playback rate. 0 is paused, 1 is playing at the normal speed.
+	Rate float64 `plist:"rate"`
+
+	// LoadedTimeRanges represents the ranges of content that have been buffered.
+	LoadedTimeRanges []TimeRange `plist:"loadedTimeRanges"`
+
+	// SeekableTimeRanges represents the ranges of content that can be seeked.
+	SeekableTimeRanges []TimeRange `plist:"seekableTimeRanges"`
+
+	// PlaybackBufferEmpty, if true, playback has consumed all buffered content.
+	PlaybackBufferEmpty bool `plist:"playbackBufferEmpty"`
+
+	// PlaybackBufferFull, if true, the buffer of content is full.
+	PlaybackBufferFull bool `plist:"playbackBufferFull"`
+
+	// PlaybackLikelyToKeepUp, if true, playback is likely to continue without stalling.
+	PlaybackLikelyToKeepUp bool `plist:"playbackLikelyToKeepUp"`
+
+	// StallCount represents the number of times playback has stalled.
+	StallCount int `plist:"stallCount"`
+
+	// UUID identifies the playing content.
+	UUID string `plist:"uuid"`
+}
+
+// A TimeRange is a range of content in seconds.
+type TimeRange struct {
+	// Start represents the start of range in seconds.
+	Start float64 `plist:"start"`
+
+	// Duration represents the length of range in seconds.
+	Duration float64 `plist:"duration"`
+}
+
+// End returns the end of range in seconds.
+func (r TimeRange) End() float64 {
+	return r.Start + r.Duration
+}
+
+// A ScrubPosition is the position of playing content reported by GET /scrub.
+type ScrubPosition struct {
+	// Duration represents the length of content (second).
+	Duration float64 `plist:"duration"`
+
+	// Position represents the current position (second).
+	Position float64 `plist:"position"`
+}
+
+// clampPosition returns position clamped to the nearest seekable range,
+// or to the length of content if the device reports no seekable ranges.
+func (info *PlaybackInfo) clampPosition(position float64) float64 {
+	if len(info.SeekableTimeRanges) == 0 {
+		if info.Duration > 0 {
+			position = math.Min(position, info.Duration)
+		}
+		return math.Max(position, 0)
+	}
+
+	nearest, distance := 0.0, math.Inf(1)
+	for _, r := range info.SeekableTimeRanges {
+		clamped := math.Max(r.Start, math.Min(position, r.End()))
+		if d := math.Abs(clamped - position); d < distance {
+			nearest, distance = clamped, d
+		}
+	}
+	return nearest
 }
 
 type Client struct {
-	connection *connection
+	connection       *connection
+	bodyFormat       BodyFormat
+	feedbackInterval time.Duration
+	pollInterval     time.Duration
+	readyTimeout     time.Duration
+	photoFetch       *FetchParam
+	imagePipeline    *ImagePipeline
+
+	mu           sync.Mutex
+	serverInfo   *ServerInfo
+	cachedPhotos map[string]*cachedPhoto
+	playlist     []PlaylistItem
+	media        *MediaServer
+	ownsMedia    bool
+
+	// playingUUID is the uuid of the last /play, and stoppedBySender is
+	// whether it has been stopped by Stop().
+	playingUUID     string
+	stoppedBySender bool
+
+	// sample is the last known position for EstimatedPosition().
+	sample *positionSample
+
+	// featuresProbed is whether /server-info has been fetched by probeFeatures().
+	featuresProbed bool
 }
 
 // SlideTransition represents transition that used when show the picture.
@@ -45,21 +142,82 @@ const (
 	SlideRight    SlideTransition = "SlideRight"
 )
 
-var (
-	requestInverval = time.Second
-)
+const clientProcName = "go-airplay"
 
 type ClientParam struct {
 	Addr     string
 	Port     int
 	Password string
+
+	// BodyFormat overrides the format of request bodies.
+	// By default, it is chosen by the features of the device,
+	// which are fetched from /server-info before the first playback if unknown.
+	BodyFormat BodyFormat
+
+	// FeedbackInterval is the interval of POST /feedback to keep the session open during playback.
+	// By default, it is sent every 2 seconds only to tvOS and AirPlay 2 receivers,
+	// which are detected by the features same as BodyFormat.
+	// If negative, it is never sent.
+	FeedbackInterval time.Duration
+
+	// PhotoFetch is the safeguards for fetching remote pictures given to Photo().
+	// If nil, the defaults of FetchParam are used.
+	PhotoFetch *FetchParam
+
+	// ImagePipeline prepares pictures before they are sent to the device.
+	// If nil, pictures are sent as they are.
+	ImagePipeline *ImagePipeline
+
+	// MediaServer serves local files given to PlayFile().
+	// If nil, a server is started on the interface that routes to the device when needed.
+	MediaServer *MediaServer
+
+	// HTTPClient sends requests to the device.
+	// If nil, a client that keeps one persistent connection to the device is used.
+	// See Transport for the reverse connection.
+	HTTPClient *http.Client
+
+	// Transport is the RoundTripper of the default client. It is ignored if HTTPClient is set.
+	//
+	// The reverse connection used by Events() and Slideshow is dialed by Dialer, not by HTTPClient or Transport.
+	// So if either is set without Dialer, the reverse connection is not used:
+	// Events() polls playback informations, and Slideshow.Start() fails with ErrReverseUnavailable.
+	Transport http.RoundTripper
+
+	// Dialer dials the device for the default client and the reverse connection.
+	// Set it together with HTTPClient or Transport to enable the reverse connection.
+	Dialer *net.Dialer
+
+	// ConnectTimeout is the timeout of dialing the device. By default, it is 30 seconds.
+	ConnectTimeout time.Duration
+
+	// RequestTimeout is the timeout of each request including reading the response body.
+	// By default, requests have no timeout.
+	RequestTimeout time.Duration
+
+	// ReadyTimeout is the timeout of waiting for content to become ready to play.
+	// By default, it is 10 seconds. It can be overridden by PlaybackOptions.
+	ReadyTimeout time.Duration
+
+	// PollInterval is the interval of polling playback informations.
+	// By default, it is 1 second. It can be overridden by PlaybackOptions.
+	PollInterval time.Duration
 }
 
 // FirstClient return the AirPlay Client that has the first found AirPlay device in LAN
 func FirstClient() (*Client, error) {
-	device := FirstDevice()
+	return FirstClientContext(context.Background())
+}
+
+// FirstClientContext is like FirstClient but stops searching when ctx is done.
+func FirstClientContext(ctx context.Context) (*Client, error) {
+	device, err := FirstDeviceContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	if device.Name == "" {
-		return nil, errors.New("AirPlay devices not found")
+		return nil, ErrNoDevices
 	}
 
 	return &Client{connection: newConnection(device)}, nil
@@ -74,9 +232,17 @@ func NewClient(params *ClientParam) (*Client, error) {
 		params.Port = 7000
 	}
 
-	client := &Client{}
+	client := &Client{
+		bodyFormat:       params.BodyFormat,
+		feedbackInterval: params.FeedbackInterval,
+		pollInterval:     params.PollInterval,
+		readyTimeout:     params.ReadyTimeout,
+		photoFetch:       params.PhotoFetch,
+		imagePipeline:    params.ImagePipeline,
+		media:            params.MediaServer,
+	}
 	device := Device{Addr: params.Addr, Port: params.Port}
-	client.connection = newConnection(device)
+	client.connection = newConnectionFor(device, params)
 
 	if params.Password != "" {
 		client.SetPassword(params.Password)
@@ -85,77 +251,244 @@ func NewClient(params *ClientParam) (*Client, error) {
 	return client, nil
 }
 
-func (c Client) SetPassword(password string) {
+func (c *Client) SetPassword(password string) {
 	c.connection.setPassword(password)
 }
 
+// Close ends the session with the device and closes the persistent connection.
+// The media server started by the client is also stopped.
+func (c *Client) Close() error {
+	c.connection.close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ownsMedia {
+		server := c.media
+		c.media = nil
+		c.ownsMedia = false
+		return server.Close()
+	}
+	return nil
+}
+
 // Play start content playback.
 //
 // When playback is finished, sends termination status on the returned channel.
-// If non-nil, not a successful termination.
+// If non-nil, not a successful termination. See PlayWithResult() for why playback has ended.
 func (c *Client) Play(url string) <-chan error {
-	return c.PlayAt(url, 0.0)
+	return c.PlayContext(context.Background(), url)
+}
+
+// PlayContext is like Play but stops playback monitoring when ctx is done.
+//
+// If ctx is done before playback is finished, ctx.Err() is sent on the returned channel.
+func (c *Client) PlayContext(ctx context.Context, url string) <-chan error {
+	return c.PlayAtContext(ctx, url, 0.0)
 }
 
 // PlayAt start content playback by specifying the start position.
 //
+// position is a fraction of the length of content between 0 (0%) to 1 (100%),
+// not seconds. See PlayFrom() to specify the position by duration.
+//
 // Returned channel is the same as Play().
 func (c *Client) PlayAt(url string, position float64) <-chan error {
-	ch := make(chan error, 1)
-	body := fmt.Sprintf("Content-Location: %s\nStart-Position: %f\n", url, position)
+	return c.PlayAtContext(context.Background(), url, position)
+}
 
-	go func() {
-		if _, err := c.connection.post("play", strings.NewReader(body)); err != nil {
-			ch <- err
-			return
-		}
+// PlayAtContext is like PlayAt but stops playback monitoring when ctx is done.
+//
+// Returned channel is the same as PlayContext().
+func (c *Client) PlayAtContext(ctx context.Context, url string, position float64) <-chan error {
+	return c.PlayWithOptions(ctx, url, &PlaybackOptions{StartPosition: position})
+}
 
-		if err := c.waitForReadyToPlay(); err != nil {
-			ch <- err
-			return
-		}
+// PlayFrom start content playback from the position d from the beginning.
+//
+// Returned channel is the same as Play().
+func (c *Client) PlayFrom(url string, d time.Duration) <-chan error {
+	return c.PlayFromContext(context.Background(), url, d)
+}
 
-		interval := time.Tick(requestInverval)
+// PlayFromContext is like PlayFrom but stops playback monitoring when ctx is done.
+//
+// Returned channel is the same as PlayContext().
+func (c *Client) PlayFromContext(ctx context.Context, url string, d time.Duration) <-chan error {
+	return c.PlayWithOptions(ctx, url, &PlaybackOptions{StartTime: d})
+}
 
-		for {
-			info, err := c.GetPlaybackInfo()
+func (c *Client) play(ctx context.Context, req *playRequest) error {
+	c.probeFeatures(ctx)
 
-			if err != nil {
-				ch <- err
-				return
-			}
+	req.UUID = newUUID()
+	req.ClientProcName = clientProcName
+
+	codec := c.codec()
+	body, err := codec.encodePlay(req)
+	if err != nil {
+		return err
+	}
 
-			if !info.IsReadyToPlay {
-				break
-			}
+	header := http.Header{
+		"Content-Type": {codec.contentType()},
+	}
+	if err := c.send(ctx, "play", bytes.NewReader(body), header); err != nil {
+		return err
+	}
 
-			<-interval
-		}
+	// New content replaces the playlist on the device.
+	c.mu.Lock()
+	c.playlist = nil
+	c.playingUUID = req.UUID
+	c.stoppedBySender = false
+	c.sample = nil
+	c.mu.Unlock()
+	return nil
+}
 
-		ch <- nil
-	}()
+// feedback returns the interval of POST /feedback. 0 means never sent.
+func (c *Client) feedback() time.Duration {
+	switch {
+	case c.feedbackInterval < 0:
+		return 0
+	case c.feedbackInterval > 0:
+		return c.feedbackInterval
+	case isModernReceiver(c.Features()):
+		return defaultFeedbackInterval
+	}
+	return 0
+}
 
-	return ch
+// codec returns the codec of request bodies for the device.
+func (c *Client) codec() codec {
+	return codecFor(c.bodyFormat, c.Features())
 }
 
 // Stop exits content playback.
-func (c *Client) Stop() {
-	c.connection.post("stop", nil)
+func (c *Client) Stop() error {
+	return c.StopContext(context.Background())
+}
+
+// StopContext is like Stop but cancels the request when ctx is done.
+func (c *Client) StopContext(ctx context.Context) error {
+	if err := c.send(ctx, "stop", nil, http.Header{}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.stoppedBySender = true
+	c.sample = nil
+	c.mu.Unlock()
+	return nil
 }
 
 // Scrub seeks at position seconds in playing content.
-func (c *Client) Scrub(position float64) {
+func (c *Client) Scrub(position float64) error {
+	return c.ScrubContext(context.Background(), position)
+}
+
+// ScrubContext is like Scrub but cancels the request when ctx is done.
+func (c *Client) ScrubContext(ctx context.Context, position float64) error {
 	query := fmt.Sprintf("?position=%f", position)
-	c.connection.post("scrub"+query, nil)
+	if err := c.send(ctx, "scrub"+query, nil, http.Header{}); err != nil {
+		return err
+	}
+
+	c.samplePosition(position, 0)
+	return nil
+}
+
+// Position retrieves the current position of playing content by GET /scrub.
+//
+// It is much lighter than GetPlaybackInfo(), so suitable for polling at high frequency.
+func (c *Client) Position(ctx context.Context) (*ScrubPosition, error) {
+	response, err := c.connection.get(ctx, "scrub")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The body is "text/parameters" even if the device does not specify Content-Type.
+	position := &ScrubPosition{}
+	if err := decodeBody(contentTypeTextParameters, body, position); err != nil {
+		return nil, err
+	}
+
+	// Nothing is playing if the duration is unknown.
+	if position.Duration > 0 {
+		c.samplePosition(position.Position, position.Duration)
+	}
+	return position, nil
+}
+
+// SeekTo seeks at the position d from the beginning of playing content.
+func (c *Client) SeekTo(d time.Duration) error {
+	return c.SeekToContext(context.Background(), d)
+}
+
+// SeekToContext is like SeekTo but cancels the request when ctx is done.
+func (c *Client) SeekToContext(ctx context.Context, d time.Duration) error {
+	return c.ScrubContext(ctx, d.Seconds())
+}
+
+// SeekBy seeks forward (or backward if negative) by delta from the current position.
+//
+// The position is clamped to the seekable ranges of playing content.
+func (c *Client) SeekBy(delta time.Duration) error {
+	return c.SeekByContext(context.Background(), delta)
+}
+
+// SeekByContext is like SeekBy but cancels the requests when ctx is done.
+func (c *Client) SeekByContext(ctx context.Context, delta time.Duration) error {
+	info, err := c.GetPlaybackInfoContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.ScrubContext(ctx, info.clampPosition(info.Position+delta.Seconds()))
+}
+
+// SeekToPercent seeks at percent (between 0 to 100) of the length of playing content.
+//
+// The position is clamped to the seekable ranges of playing content.
+func (c *Client) SeekToPercent(percent float64) error {
+	return c.SeekToPercentContext(context.Background(), percent)
+}
+
+// SeekToPercentContext is like SeekToPercent but cancels the requests when ctx is done.
+func (c *Client) SeekToPercentContext(ctx context.Context, percent float64) error {
+	info, err := c.GetPlaybackInfoContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	percent = math.Max(0, math.Min(percent, 100))
+	return c.ScrubContext(ctx, info.clampPosition(info.Duration*percent/100))
 }
 
 // Rate change the playback rate in playing content.
 //
 // If rate is 0, content is paused.
 // if rate is 1, content playing at the normal speed.
-func (c *Client) Rate(rate float64) {
+func (c *Client) Rate(rate float64) error {
+	return c.RateContext(context.Background(), rate)
+}
+
+// RateContext is like Rate but cancels the request when ctx is done.
+func (c *Client) RateContext(ctx context.Context, rate float64) error {
 	query := fmt.Sprintf("?value=%f", rate)
-	c.connection.post("rate"+query, nil)
+	if err := c.send(ctx, "rate"+query, nil, http.Header{}); err != nil {
+		return err
+	}
+
+	c.sampleRate(rate)
+	return nil
 }
 
 // Photo show a JPEG picture. It can specify both remote or local file.
@@ -164,114 +497,127 @@ func (c *Client) Rate(rate float64) {
 //
 //     // local file
 //     client.Photo("/path/to/gopher.jpg")
+//     client.Photo("file:///path/to/gopher.jpg")
 //
 //     // remote file
 //     client.Photo("http://blog.golang.org/gopher/plush.jpg")
 //
-func (c *Client) Photo(path string) {
-	c.PhotoWithSlide(path, SlideNone)
+// Remote files are fetched with ClientParam.PhotoFetch.
+func (c *Client) Photo(path string) error {
+	return c.PhotoWithSlide(path, SlideNone)
+}
+
+// PhotoContext is like Photo but cancels the requests when ctx is done.
+func (c *Client) PhotoContext(ctx context.Context, path string) error {
+	return c.PhotoWithSlideContext(ctx, path, SlideNone)
 }
 
 // PhotoWithSlide show a JPEG picture in the transition specified.
-func (c *Client) PhotoWithSlide(path string, transition SlideTransition) {
-	url, err := url.Parse(path)
-	if err != nil {
-		log.Fatal(err)
-	}
+func (c *Client) PhotoWithSlide(path string, transition SlideTransition) error {
+	return c.PhotoWithSlideContext(context.Background(), path, transition)
+}
 
-	var image *bytes.Reader
+// PhotoWithSlideContext is like PhotoWithSlide but cancels the requests when ctx is done.
+func (c *Client) PhotoWithSlideContext(ctx context.Context, path string, transition SlideTransition) error {
+	return c.ShowPhoto(ctx, c.photoFromPath(path), transition)
+}
 
-	if url.Scheme == "http" || url.Scheme == "https" {
-		image, err = remoteImageReader(path)
-	} else {
-		image, err = localImageReader(path)
-	}
+// ShowPhoto show a picture provided by src in the transition specified.
+//
+// A trivial example:
+//
+//     // in-memory image
+//     client.ShowPhoto(ctx, airplay.PhotoFromImage(chart), airplay.SlideDissolve)
+//
+//     // remote file with safeguards
+//     src := airplay.PhotoFromURL("https://example.com/card.jpg", &airplay.FetchParam{
+//         Timeout:      5 * time.Second,
+//         MaxSize:      10 << 20,
+//         ContentTypes: []string{"image/"},
+//     })
+//     client.ShowPhoto(ctx, src, airplay.SlideNone)
+//
+func (c *Client) ShowPhoto(ctx context.Context, src PhotoSource, transition SlideTransition) error {
+	data, err := c.readPhoto(ctx, src)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	header := http.Header{
 		"X-Apple-Transition": {string(transition)},
 	}
-	c.connection.postWithHeader("photo", image, header)
+	return c.send(ctx, "photo", bytes.NewReader(data), header)
+}
+
+func (c *Client) photoFromPath(path string) PhotoSource {
+	return photoFromPath(path, c.photoFetch)
+}
+
+// readPhoto reads a picture from src and prepares it by ClientParam.ImagePipeline.
+func (c *Client) readPhoto(ctx context.Context, src PhotoSource) ([]byte, error) {
+	data, err := src.ReadPhoto(ctx)
+	if err != nil || c.imagePipeline == nil {
+		return data, err
+	}
+
+	var width, height int
+	c.mu.Lock()
+	if c.serverInfo != nil {
+		width, height = c.serverInfo.DisplaySize()
+	}
+	c.mu.Unlock()
+
+	return c.imagePipeline.Process(data, width, height)
 }
 
 // GetPlaybackInfo retrieves playback informations.
 func (c *Client) GetPlaybackInfo() (*PlaybackInfo, error) {
-	response, err := c.connection.get("playback-info")
+	return c.GetPlaybackInfoContext(context.Background())
+}
+
+// GetPlaybackInfoContext is like GetPlaybackInfo but cancels the request when ctx is done.
+func (c *Client) GetPlaybackInfoContext(ctx context.Context) (*PlaybackInfo, error) {
+	response, err := c.connection.get(ctx, "playback-info")
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
 
-	body, err := convertBytesReader(response.Body)
+	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	decoder := plist.NewDecoder(body)
 	info := &PlaybackInfo{}
-	if err := decoder.Decode(info); err != nil {
+	if err := decodeBody(response.Header.Get("Content-Type"), body, info); err != nil {
 		return nil, err
 	}
 
-	switch t := info.ReadyToPlayValue.(type) {
-	case uint64: // AppleTV 4G
-		info.IsReadyToPlay = (t == 1)
-	case bool: // AppleTV 2G, 3G
-		info.IsReadyToPlay = t
-	}
-
+	c.samplePlaybackInfo(info)
+	c.prunePlaylist(info)
 	return info, nil
 }
 
-func (c *Client) waitForReadyToPlay() error {
-	interval := time.Tick(requestInverval)
-	timeout := time.After(10 * time.Second)
-
-	for {
-		select {
-		case <-timeout:
-			return errors.New("timeout while waiting for ready to play")
-		case <-interval:
-			info, err := c.GetPlaybackInfo()
-
-			if err != nil {
-				return err
-			}
-
-			if info.IsReadyToPlay {
-				return nil
-			}
-		}
-	}
-}
-
-func localImageReader(path string) (*bytes.Reader, error) {
-	fn, err := os.Open(path)
+// send posts a request to the device and discards the response body.
+func (c *Client) send(ctx context.Context, path string, body io.ReadSeeker, header http.Header) error {
+	response, err := c.connection.postWithHeader(ctx, path, body, header)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer fn.Close()
+	discardBody(response)
 
-	return convertBytesReader(fn)
+	return nil
 }
 
-func remoteImageReader(url string) (*bytes.Reader, error) {
-	response, err := http.Get(url)
+// sendPlist posts v encoded in binary plist to the device.
+func (c *Client) sendPlist(ctx context.Context, path string, v interface{}) error {
+	body, err := plist.Marshal(v, plist.BinaryFormat)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer response.Body.Close()
-
-	return convertBytesReader(response.Body)
-}
 
-func convertBytesReader(r io.Reader) (*bytes.Reader, error) {
-	body, err := ioutil.ReadAll(r)
-	if err != nil {
-		return nil, err
+	header := http.Header{
+		"Content-Type": {contentTypeBinaryPlist},
 	}
-
-	return bytes.NewReader(body), nil
+	return c.send(ctx, path, bytes.NewReader(body), header)
 }
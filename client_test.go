@@ -1,6 +1,8 @@
 package airplay
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +15,7 @@ import (
 
 	"time"
 
+	"github.com/DHowett/go-plist"
 	"github.com/gongo/text-parameters"
 )
 
@@ -47,9 +50,56 @@ var (
 	<real>36.00000</real>
 	<key>position</key>
 	<real>18.00000</real>
+	<key>rate</key>
+	<real>1.00000</real>
 	<key>readyToPlay</key>
 	<true/>
 </dict>
+</plist>`
+
+	fullPlaybackInfo = `
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>duration</key>
+	<real>1801.1</real>
+	<key>loadedTimeRanges</key>
+	<array>
+		<dict>
+			<key>duration</key>
+			<real>51.0</real>
+			<key>start</key>
+			<real>18.5</real>
+		</dict>
+	</array>
+	<key>playbackBufferEmpty</key>
+	<true/>
+	<key>playbackBufferFull</key>
+	<integer>0</integer>
+	<key>playbackLikelyToKeepUp</key>
+	<integer>1</integer>
+	<key>position</key>
+	<real>18.5</real>
+	<key>rate</key>
+	<integer>1</integer>
+	<key>readyToPlay</key>
+	<integer>1</integer>
+	<key>readyToPlayMs</key>
+	<integer>1550</integer>
+	<key>seekableTimeRanges</key>
+	<array>
+		<dict>
+			<key>duration</key>
+			<real>1801.1</real>
+			<key>start</key>
+			<integer>0</integer>
+		</dict>
+	</array>
+	<key>stallCount</key>
+	<real>2</real>
+	<key>uuid</key>
+	<string>AAAAA-BBBBB-CCCCC-DDDDD-EEEEE</string>
+</dict>
 </plist>`
 
 	playingPlaybackInfoAt4G = `
@@ -62,18 +112,18 @@ var (
 </plist>`
 )
 
+// testPollInterval is the interval of polling playback informations in tests.
+const testPollInterval = time.Millisecond
+
 type playbackInfoParam struct {
 	Location string  `parameters:"Content-Location"`
 	Position float64 `parameters:"Start-Position"`
-}
-
-func TestMain(m *testing.M) {
-	requestInverval = time.Millisecond
-	os.Exit(m.Run())
+	Seconds  float64 `parameters:"Start-Position-Seconds"`
 }
 
 func TestPost(t *testing.T) {
 	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
 		{"POST", "/play"},
 		{"GET", "/playback-info"},
 		{"GET", "/playback-info"},
@@ -114,6 +164,7 @@ func TestPost(t *testing.T) {
 
 func TestPostAt(t *testing.T) {
 	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
 		{"POST", "/play"},
 		{"GET", "/playback-info"},
 		{"GET", "/playback-info"},
@@ -146,10 +197,137 @@ func TestPostAt(t *testing.T) {
 	<-ch
 }
 
+func TestPlayContextCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/playback-info" {
+			w.Write([]byte(playingPlaybackInfo))
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := getTestClient(t, ts)
+	ch := client.PlayContext(ctx, "http://movie.example.com/go.mp4")
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	select {
+	case err := <-ch:
+		if err != context.Canceled {
+			t.Fatalf("It should occurs [context canceled] error (actual %v)", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Playback monitoring was not stopped by context")
+	}
+}
+
+func TestPlayWithBinaryPlist(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
+		{"POST", "/play"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+	}
+	responses := []map[string]interface{}{
+		{"readyToPlay": true, "duration": 36.0, "position": 18.0},
+		{"readyToPlay": false},
+	}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/play":
+			if req.Header.Get("Content-Type") != "application/x-apple-binary-plist" {
+				t.Fatalf("Incorrect content type (actual %s)", req.Header.Get("Content-Type"))
+			}
+
+			body, _ := ioutil.ReadAll(req.Body)
+			params := map[string]interface{}{}
+			if _, err := plist.Unmarshal(body, &params); err != nil {
+				t.Fatal(err)
+			}
+
+			if params["Content-Location"] != "http://movie.example.com/go.mp4" || params["Start-Position"] != 0.25 {
+				t.Fatalf("Incorrect request body (actual %v)", params)
+			}
+
+			if uuid, _ := params["uuid"].(string); uuid == "" {
+				t.Fatalf("Not found uuid in request body (actual %v)", params)
+			}
+		case "/playback-info":
+			body, err := plist.Marshal(responses[0], plist.BinaryFormat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			responses = responses[1:]
+
+			w.Header().Set("Content-Type", "application/x-apple-binary-plist")
+			w.Write(body)
+		}
+	})
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, err := NewClient(&ClientParam{Addr: addr, Port: port, BodyFormat: BodyFormatBinaryPlist, PollInterval: testPollInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-client.PlayAt("http://movie.example.com/go.mp4", 0.25); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodecForFeatures(t *testing.T) {
+	if _, ok := codecFor(BodyFormatAuto, FeatureVideo|FeaturePhoto).(textParametersCodec); !ok {
+		t.Error("AirPlay 1 devices should use text/parameters")
+	}
+
+	if _, ok := codecFor(BodyFormatAuto, FeatureVideo|FeatureUnifiedMediaControl).(binaryPlistCodec); !ok {
+		t.Error("AirPlay 2 devices should use binary plist")
+	}
+
+	if _, ok := codecFor(BodyFormatTextParameters, FeatureUnifiedMediaControl).(textParametersCodec); !ok {
+		t.Error("BodyFormat should override the features")
+	}
+}
+
 func TestStop(t *testing.T) {
 	ts := airTestServer(t, []testExpectRequest{{"POST", "/stop"}}, nil)
 	client := getTestClient(t, ts)
-	client.Stop()
+	if err := client.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopWithErrorStatus(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"POST", "/stop"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("gongo"))
+	})
+	client := getTestClient(t, ts)
+
+	err := client.Stop()
+	requestErr, ok := err.(*RequestError)
+	if !ok {
+		t.Fatalf("It should occurs *RequestError (actual %v)", err)
+	}
+
+	statusErr, ok := requestErr.Err.(*StatusError)
+	if !ok || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("It should occurs *StatusError with 500 (actual %v)", requestErr.Err)
+	}
+
+	if requestErr.Method != "POST" || requestErr.Endpoint != "stop" {
+		t.Fatalf("Incorrect request of error (actual %s /%s)", requestErr.Method, requestErr.Endpoint)
+	}
+
+	if statusErr.Endpoint != "stop" || string(statusErr.Body) != "gongo" {
+		t.Fatalf("Incorrect endpoint or body of *StatusError (actual %s, %s)", statusErr.Endpoint, statusErr.Body)
+	}
+
+	statusErr = nil
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("errors.As should find *StatusError (actual %v)", err)
+	}
 }
 
 func TestScrub(t *testing.T) {
@@ -171,7 +349,128 @@ func TestScrub(t *testing.T) {
 		}
 	})
 	client := getTestClient(t, ts)
-	client.Scrub(position)
+	if err := client.Scrub(position); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlayFrom(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
+		{"POST", "/play"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+	}
+	responseXMLs := []string{
+		playingPlaybackInfo,
+		stopPlaybackInfo,
+	}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/play" {
+			u := &playbackInfoParam{}
+			decoder := parameters.NewDecorder(req.Body)
+			decoder.Decode(u)
+
+			if u.Seconds != 90.0 || u.Position != 0.0 {
+				t.Fatalf("Incorrect request position (actual %f seconds, %f)", u.Seconds, u.Position)
+			}
+		}
+
+		if req.URL.Path == "/playback-info" {
+			xml := responseXMLs[0]
+			responseXMLs = responseXMLs[1:]
+			w.Write([]byte(xml))
+		}
+	})
+
+	client := getTestClient(t, ts)
+	if err := <-client.PlayFrom("http://movie.example.com/go.mp4", 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSeekServer(t *testing.T, info map[string]interface{}, expect float64) *httptest.Server {
+	expectRequests := []testExpectRequest{
+		{"GET", "/playback-info"},
+		{"POST", "/scrub"},
+	}
+
+	return airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/playback-info":
+			body, _ := plist.Marshal(info, plist.XMLFormat)
+			w.Write(body)
+		case "/scrub":
+			position, _ := strconv.ParseFloat(req.URL.Query().Get("position"), 64)
+			if position != expect {
+				t.Fatalf("Incorrect position (expected %f, actual %f)", expect, position)
+			}
+		}
+	})
+}
+
+func TestSeekBy(t *testing.T) {
+	seekable := []interface{}{
+		map[string]interface{}{"start": 10.0, "duration": 80.0},
+	}
+	tests := []struct {
+		delta  time.Duration
+		expect float64
+	}{
+		{15 * time.Second, 45.0},
+		{-25 * time.Second, 10.0},
+		{time.Minute, 90.0},
+	}
+
+	for _, test := range tests {
+		info := map[string]interface{}{"duration": 100.0, "position": 30.0, "seekableTimeRanges": seekable}
+		ts := testSeekServer(t, info, test.expect)
+
+		client := getTestClient(t, ts)
+		if err := client.SeekBy(test.delta); err != nil {
+			t.Fatal(err)
+		}
+		ts.Close()
+	}
+}
+
+func TestSeekToPercent(t *testing.T) {
+	tests := []struct {
+		percent float64
+		expect  float64
+	}{
+		{25, 50.0},
+		{150, 200.0},
+		{-1, 0.0},
+	}
+
+	for _, test := range tests {
+		info := map[string]interface{}{"duration": 200.0, "position": 30.0}
+		ts := testSeekServer(t, info, test.expect)
+
+		client := getTestClient(t, ts)
+		if err := client.SeekToPercent(test.percent); err != nil {
+			t.Fatal(err)
+		}
+		ts.Close()
+	}
+}
+
+func TestPosition(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/scrub"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("duration: 83.124794\nposition: 14.467000\n"))
+	})
+
+	client := getTestClient(t, ts)
+	position, err := client.Position(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if position.Duration != 83.124794 || position.Position != 14.467 {
+		t.Fatalf("Incorrect position (actual %+v)", position)
+	}
 }
 
 func TestRate(t *testing.T) {
@@ -192,7 +491,9 @@ func TestRate(t *testing.T) {
 		}
 	})
 	client := getTestClient(t, ts)
-	client.Rate(rate)
+	if err := client.Rate(rate); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestPhotoLocalFile(t *testing.T) {
@@ -224,7 +525,18 @@ func TestPhotoLocalFile(t *testing.T) {
 	})
 
 	client := getTestClient(t, ts)
-	client.Photo(f.Name())
+	if err := client.Photo(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPhotoWithUnreadableFile(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{}, nil)
+	client := getTestClient(t, ts)
+
+	if err := client.Photo("/path/to/not/exist.jpg"); err == nil {
+		t.Fatal("It should occurs [file not found] error")
+	}
 }
 
 func TestPhotoRemoteFile(t *testing.T) {
@@ -246,7 +558,9 @@ func TestPhotoRemoteFile(t *testing.T) {
 	})
 
 	client := getTestClient(t, ts)
-	client.Photo(remoteTs.URL)
+	if err := client.Photo(remoteTs.URL); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestPhotoWithSlide(t *testing.T) {
@@ -262,7 +576,9 @@ func TestPhotoWithSlide(t *testing.T) {
 	})
 
 	client := getTestClient(t, ts)
-	client.PhotoWithSlide(remoteTs.URL, SlideRight)
+	if err := client.PhotoWithSlide(remoteTs.URL, SlideRight); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestGetPlaybackInfo(t *testing.T) {
@@ -326,8 +642,49 @@ func TestGetPlaybackInfoWithVariousVersion(t *testing.T) {
 	}
 }
 
+func TestGetPlaybackInfoWithAllFields(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/playback-info"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(fullPlaybackInfo))
+	})
+
+	client := getTestClient(t, ts)
+	info, err := client.GetPlaybackInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.IsReadyToPlay || info.ReadyToPlayMs != 1550 {
+		t.Errorf("Incorrect ready to play (actual %v, %d)", info.IsReadyToPlay, info.ReadyToPlayMs)
+	}
+
+	if info.Duration != 1801.1 || info.Position != 18.5 || info.Rate != 1.0 {
+		t.Errorf("Incorrect duration, position or rate (actual %f, %f, %f)", info.Duration, info.Position, info.Rate)
+	}
+
+	if len(info.LoadedTimeRanges) != 1 || info.LoadedTimeRanges[0].End() != 69.5 {
+		t.Errorf("Incorrect loaded time ranges (actual %v)", info.LoadedTimeRanges)
+	}
+
+	if len(info.SeekableTimeRanges) != 1 || info.SeekableTimeRanges[0] != (TimeRange{Start: 0, Duration: 1801.1}) {
+		t.Errorf("Incorrect seekable time ranges (actual %v)", info.SeekableTimeRanges)
+	}
+
+	if !info.PlaybackBufferEmpty || info.PlaybackBufferFull || !info.PlaybackLikelyToKeepUp {
+		t.Errorf("Incorrect buffering flags (actual %v, %v, %v)", info.PlaybackBufferEmpty, info.PlaybackBufferFull, info.PlaybackLikelyToKeepUp)
+	}
+
+	if info.StallCount != 2 {
+		t.Errorf("Incorrect stall count (actual %d)", info.StallCount)
+	}
+
+	if info.UUID != "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE" {
+		t.Errorf("Incorrect uuid (actual %s)", info.UUID)
+	}
+}
+
 func TestClientToPasswordRequiredDevice(t *testing.T) {
 	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
 		{"POST", "/play"},
 		{"POST", "/play"},
 		{"GET", "/playback-info"},
@@ -381,6 +738,7 @@ func TestClientToPasswordRequiredDevice(t *testing.T) {
 
 func TestClientWithErrorAboutPassword(t *testing.T) {
 	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
 		{"POST", "/play"},
 		{"POST", "/play"},
 		{"POST", "/play"},
@@ -388,6 +746,10 @@ func TestClientWithErrorAboutPassword(t *testing.T) {
 	playRequestCount := 1
 
 	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/play" {
+			return
+		}
+
 		switch playRequestCount {
 		case 1, 2:
 			w.Header().Add("WWW-Authenticate", "Digest realm=\"AirPlay\", nonce=\"4444\"")
@@ -417,14 +779,20 @@ func TestClientWithErrorAboutPassword(t *testing.T) {
 
 	client := getTestClient(t, ts)
 	ch = client.Play("http://movie.example.com/go.mp4")
-	if err := <-ch; err == nil {
-		t.Fatal("It should occurs [password required] error")
+	if err := <-ch; !errors.Is(err, ErrPasswordRequired) {
+		t.Fatalf("It should occurs [password required] error (actual %v)", err)
 	}
 
 	client.SetPassword("wrongpassword")
 	ch = client.Play("http://movie.example.com/go.mp4")
-	if err := <-ch; err == nil {
-		t.Fatal("It should occurs [wrong password] error")
+	err := <-ch
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("It should occurs [wrong password] error (actual %v)", err)
+	}
+
+	var deviceErr *DeviceError
+	if !errors.As(err, &deviceErr) || deviceErr.Device != client.connection.device {
+		t.Fatalf("It should occurs *DeviceError with the device (actual %v)", err)
 	}
 }
 
@@ -450,7 +818,7 @@ func airTestServer(t *testing.T, requests []testExpectRequest, handler testHunde
 
 func getTestClient(t *testing.T, ts *httptest.Server) *Client {
 	addr, port := getAddrAndPort(t, ts.URL)
-	client, err := NewClient(&ClientParam{Addr: addr, Port: port})
+	client, err := NewClient(&ClientParam{Addr: addr, Port: port, PollInterval: testPollInterval})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,121 @@
+package airplay
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+
+	"github.com/DHowett/go-plist"
+)
+
+const (
+	contentTypeTextParameters = "text/parameters"
+	contentTypeBinaryPlist    = "application/x-apple-binary-plist"
+	contentTypeXMLPlist       = "text/x-apple-plist+xml"
+)
+
+// BodyFormat represents the format of request bodies sent to the device.
+type BodyFormat int
+
+const (
+	// BodyFormatAuto chooses the format by the features of the device.
+	BodyFormatAuto BodyFormat = iota
+
+	// BodyFormatTextParameters is "text/parameters" understood by all devices.
+	BodyFormatTextParameters
+
+	// BodyFormatBinaryPlist is "application/x-apple-binary-plist" expected by tvOS and recent receivers.
+	BodyFormatBinaryPlist
+)
+
+// A playRequest is the parameters of POST /play.
+type playRequest struct {
+	ContentLocation      string  `plist:"Content-Location"`
+	StartPosition        float64 `plist:"Start-Position"`
+	StartPositionSeconds float64 `plist:"Start-Position-Seconds,omitempty"`
+	UUID                 string  `plist:"uuid,omitempty"`
+	ClientProcName       string  `plist:"clientProcName,omitempty"`
+	Volume               float64 `plist:"volume,omitempty"`
+}
+
+// A codec encodes request bodies in a format the device understands.
+type codec interface {
+	contentType() string
+	encodePlay(req *playRequest) ([]byte, error)
+}
+
+type textParametersCodec struct{}
+
+func (textParametersCodec) contentType() string {
+	return contentTypeTextParameters
+}
+
+func (textParametersCodec) encodePlay(req *playRequest) ([]byte, error) {
+	body := fmt.Sprintf("Content-Location: %s\nStart-Position: %f\n", req.ContentLocation, req.StartPosition)
+	if req.StartPositionSeconds > 0 {
+		body += fmt.Sprintf("Start-Position-Seconds: %f\n", req.StartPositionSeconds)
+	}
+	return []byte(body), nil
+}
+
+type binaryPlistCodec struct{}
+
+func (binaryPlistCodec) contentType() string {
+	return contentTypeBinaryPlist
+}
+
+func (binaryPlistCodec) encodePlay(req *playRequest) ([]byte, error) {
+	return plist.Marshal(req, plist.BinaryFormat)
+}
+
+// codecFor returns the codec for format.
+//
+// BodyFormatAuto chooses binary plist when the device is known to be an AirPlay 2 receiver.
+func codecFor(format BodyFormat, features Features) codec {
+	switch format {
+	case BodyFormatTextParameters:
+		return textParametersCodec{}
+	case BodyFormatBinaryPlist:
+		return binaryPlistCodec{}
+	}
+
+	if isModernReceiver(features) {
+		return binaryPlistCodec{}
+	}
+	return textParametersCodec{}
+}
+
+// isModernReceiver reports whether features is of tvOS or AirPlay 2 receivers.
+func isModernReceiver(features Features) bool {
+	return features&(FeatureUnifiedMediaControl|FeatureVideoPlayQueue) != 0
+}
+
+// decodeBody decodes a response body into v according to contentType.
+//
+// "text/parameters" is decoded by the field tags same as plist,
+// and others are decoded as plist regardless of XML or binary.
+func decodeBody(contentType string, data []byte, v interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != contentTypeTextParameters {
+		return unmarshalPlist(data, v)
+	}
+
+	values := map[string]interface{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		pair := strings.SplitN(scanner.Text(), ":", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	assignPlistValue(reflect.ValueOf(v).Elem(), values)
+	return nil
+}
@@ -1,96 +1,173 @@
 package airplay
 
 import (
-	"crypto/md5"
-	"errors"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
-	"regexp"
+	"strconv"
+	"time"
 )
 
 const (
 	digestAuthUsername = "AirPlay"
 	digestAuthRealm    = "AirPlay"
+
+	// maxAuthAttempts is the number of retries after the device challenges authentication.
+	maxAuthAttempts = 2
 )
 
 type connection struct {
-	device       Device
-	passwordHash string
+	device   Device
+	password string
+	auth     digestAuth
+	session  *session
+
+	// dialer dials the reverse connection. If nil, it is not available.
+	dialer *net.Dialer
 }
 
 func newConnection(device Device) *connection {
-	return &connection{device: device}
+	return newConnectionFor(device, &ClientParam{})
+}
+
+// newConnectionFor returns the connection to device configured by params.
+func newConnectionFor(device Device, params *ClientParam) *connection {
+	dialer := newDialer(params.ConnectTimeout)
+	if params.Dialer != nil {
+		copied := *params.Dialer
+		if params.ConnectTimeout > 0 {
+			copied.Timeout = params.ConnectTimeout
+		}
+		dialer = &copied
+	}
+
+	var client http.Client
+	if params.HTTPClient != nil {
+		client = *params.HTTPClient
+	} else {
+		client = *newHTTPClient(params.Transport, dialer)
+	}
+	if params.RequestTimeout > 0 {
+		client.Timeout = params.RequestTimeout
+	}
+
+	// The reverse connection is dialed directly, so it would bypass the custom transport.
+	if params.Dialer == nil && (params.HTTPClient != nil || params.Transport != nil) {
+		dialer = nil
+	}
+
+	return &connection{
+		device:  device,
+		session: newSession(&client),
+		dialer:  dialer,
+	}
 }
 
 func (c *connection) setPassword(password string) {
-	c.passwordHash = fmt.Sprintf("%x", md5.Sum([]byte(digestAuthUsername+":"+digestAuthRealm+":"+password)))
+	c.password = password
 }
 
-func (c *connection) get(path string) (*http.Response, error) {
-	return c.getWithHeader(path, http.Header{})
+func (c *connection) get(ctx context.Context, path string) (*http.Response, error) {
+	return c.getWithHeader(ctx, path, http.Header{})
 }
 
-func (c *connection) getWithHeader(path string, header http.Header) (*http.Response, error) {
-	return c.request("GET", path, nil, header)
+func (c *connection) getWithHeader(ctx context.Context, path string, header http.Header) (*http.Response, error) {
+	return c.request(ctx, "GET", path, nil, header)
 }
 
-func (c *connection) post(path string, body io.ReadSeeker) (*http.Response, error) {
-	return c.postWithHeader(path, body, http.Header{})
+func (c *connection) post(ctx context.Context, path string, body io.ReadSeeker) (*http.Response, error) {
+	return c.postWithHeader(ctx, path, body, http.Header{})
 }
 
-func (c *connection) postWithHeader(path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
-	return c.request("POST", path, body, header)
+func (c *connection) postWithHeader(ctx context.Context, path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
+	return c.request(ctx, "POST", path, body, header)
 }
 
-func (c *connection) request(method, path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
-	response, err := c.do(method, path, body, header)
-	if err != nil {
-		return nil, err
-	}
+func (c *connection) putWithHeader(ctx context.Context, path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
+	return c.request(ctx, "PUT", path, body, header)
+}
 
-	if response.StatusCode == http.StatusUnauthorized {
-		if c.passwordHash == "" {
-			msg := fmt.Sprintf(
-				"airplay: [ERR] Device %s:%d is required password",
-				c.device.Addr,
-				c.device.Port,
-			)
-			return nil, errors.New(msg)
+// request sends a request to the device.
+//
+// Any failure other than ctx cancellation is returned as *RequestError,
+// and a non-2xx response is treated as failure.
+//
+// Once the device challenges digest authentication, following requests are
+// authorized with the cached nonce. The request is sent again when the nonce is stale.
+func (c *connection) request(ctx context.Context, method, path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		nonce, authorized := c.authorize(header, method, path)
+
+		response, err := c.do(ctx, method, path, body, header)
+		if err != nil {
+			return nil, c.requestError(ctx, method, path, err)
 		}
 
-		token := c.authorizationHeader(response, method, path, header)
+		if response.StatusCode != http.StatusUnauthorized {
+			if response.StatusCode < 200 || response.StatusCode >= 300 {
+				err := newStatusError(path, response)
+				discardBody(response)
+				return nil, newRequestError(method, path, err)
+			}
+			return response, nil
+		}
 
-		// body is closed first c.do().
-		body.Seek(0, 0)
-		header.Add("Authorization", token)
-		response, err = c.do(method, path, body, header)
-		if err != nil {
-			return nil, err
+		discardBody(response)
+
+		if c.password == "" {
+			err := &DeviceError{Device: c.device, Err: ErrPasswordRequired}
+			return nil, newRequestError(method, path, err)
 		}
 
-		if response.StatusCode == http.StatusUnauthorized {
-			msg := fmt.Sprintf(
-				"airplay: [ERR] Wrong password to %s:%d",
-				c.device.Addr,
-				c.device.Port,
-			)
-			return nil, errors.New(msg)
+		challenge, ok := parseDigestChallenge(response.Header.Get("Www-Authenticate"))
+		rejected := authorized && !challenge.isRenewed(nonce)
+		if !ok || rejected || attempt >= maxAuthAttempts {
+			err := &DeviceError{Device: c.device, Err: ErrWrongPassword}
+			return nil, newRequestError(method, path, err)
+		}
+		c.auth.update(challenge)
+
+		// body is closed first c.do().
+		if body != nil {
+			body.Seek(0, io.SeekStart)
 		}
 	}
+}
 
-	return response, nil
+// authorize sets Authorization header by the cached challenge, and returns the nonce used.
+func (c *connection) authorize(header http.Header, method, path string) (string, bool) {
+	if c.password == "" {
+		return "", false
+	}
+
+	token, nonce, ok := c.auth.authorize(digestAuthUsername, c.password, method, "/"+path)
+	if ok {
+		header.Set("Authorization", token)
+	}
+	return nonce, ok
+}
+
+// requestError wraps the transport failure err in *RequestError unless ctx is done.
+func (c *connection) requestError(ctx context.Context, method, path string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return newRequestError(method, path, &DeviceError{Device: c.device, Err: err})
 }
 
-func (c *connection) do(method, path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
+func (c *connection) do(ctx context.Context, method, path string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
 	req, err := http.NewRequest(method, c.endpoint()+path, body)
 	if err != nil {
 		return nil, err
 	}
 
+	req = req.WithContext(ctx)
 	req.Header = header
-	client := &http.Client{}
-	response, err := client.Do(req)
+	c.session.stamp(req.Header)
+	response, err := c.session.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -98,28 +175,40 @@ func (c *connection) do(method, path string, body io.ReadSeeker, header http.Hea
 	return response, nil
 }
 
+// startFeedback starts sending POST /feedback every interval to keep the session open.
+func (c *connection) startFeedback(interval time.Duration) {
+	c.session.startFeedback(interval, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+
+		response, err := c.post(ctx, "feedback", nil)
+		if err != nil {
+			return err
+		}
+		discardBody(response)
+		return nil
+	})
+}
+
+func (c *connection) stopFeedback() {
+	c.session.stopFeedback()
+}
+
+func (c *connection) close() {
+	c.session.close()
+}
+
 func (c *connection) endpoint() string {
 	return fmt.Sprintf("http://%s:%d/", c.device.Addr, c.device.Port)
 }
 
-func (c *connection) authorizationHeader(response *http.Response, method, path string, header http.Header) string {
-	pattern := regexp.MustCompile("^Digest .*nonce=\"([^\"]+)\"")
-	results := pattern.FindStringSubmatch(response.Header.Get("Www-Authenticate"))
-	if results == nil {
-		return ""
-	}
+func (c *connection) hostPort() string {
+	return net.JoinHostPort(c.device.Addr, strconv.Itoa(c.device.Port))
+}
 
-	nonce := results[1]
-	a1 := c.passwordHash
-	a2 := fmt.Sprintf("%x", md5.Sum([]byte(method+":/"+path)))
-	resp := fmt.Sprintf("%x", md5.Sum([]byte(a1+":"+nonce+":"+a2)))
-
-	return fmt.Sprintf(
-		"Digest username=\"%s\", realm=\"%s\", uri=\"/%s\", nonce=\"%s\", response=\"%s\"",
-		digestAuthUsername,
-		digestAuthRealm,
-		path,
-		nonce,
-		resp,
-	)
+// discardBody drains and closes the response body so that the underlying
+// connection can be reused.
+func discardBody(response *http.Response) {
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
 }
@@ -1,5 +1,7 @@
 package airplay
 
+import "context"
+
 // A Device is an AirPlay Device.
 type Device struct {
 	Name  string
@@ -19,27 +21,50 @@ type DeviceExtra struct {
 
 // Devices returns all AirPlay devices in LAN.
 func Devices() []Device {
+	devices, _ := DevicesContext(context.Background())
+	return devices
+}
+
+// DevicesContext returns all AirPlay devices in LAN.
+//
+// When ctx is done, searching stops immediately and the devices found so far
+// are returned together with ctx.Err().
+func DevicesContext(ctx context.Context) ([]Device, error) {
 	devices := []Device{}
 
-	for _, entry := range searchEntry(&queryParam{}) {
+	entries, err := searchEntry(ctx, &queryParam{})
+	for _, entry := range entries {
 		devices = append(
 			devices,
 			entryToDevice(entry),
 		)
 	}
 
-	return devices
+	return devices, err
 }
 
 // FirstDevice return the first found AirPlay device in LAN.
 func FirstDevice() Device {
+	device, _ := FirstDeviceContext(context.Background())
+	return device
+}
+
+// FirstDeviceContext return the first found AirPlay device in LAN.
+//
+// When ctx is done, searching stops immediately and ctx.Err() is returned.
+// If no device is found, ErrNoDevices is returned.
+func FirstDeviceContext(ctx context.Context) (Device, error) {
 	params := &queryParam{maxCount: 1}
 
-	for _, entry := range searchEntry(params) {
-		return entryToDevice(entry)
+	entries, err := searchEntry(ctx, params)
+	for _, entry := range entries {
+		return entryToDevice(entry), nil
 	}
 
-	return Device{}
+	if err == nil {
+		err = ErrNoDevices
+	}
+	return Device{}, err
 }
 
 func entryToDevice(entry *entry) Device {
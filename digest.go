@@ -0,0 +1,208 @@
+package airplay
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+)
+
+// A digestChallenge is the parameters of "WWW-Authenticate: Digest ..." (RFC 7616).
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+	stale     bool
+}
+
+// parseDigestChallenge parses the value of WWW-Authenticate header.
+func parseDigestChallenge(header string) (*digestChallenge, bool) {
+	const scheme = "digest "
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return nil, false
+	}
+
+	params := parseAuthParams(header[len(scheme):])
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+		stale:     strings.EqualFold(params["stale"], "true"),
+	}
+
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if qop = strings.TrimSpace(qop); qop != "" {
+			challenge.qop = append(challenge.qop, qop)
+		}
+	}
+
+	if challenge.nonce == "" || challenge.hash() == nil {
+		return nil, false
+	}
+	return challenge, true
+}
+
+// parseAuthParams parses comma separated auth-params, whose values may be quoted strings.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, "\"") {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[minInt(i+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+
+		params[key] = value.String()
+	}
+}
+
+// hash returns the hash function of algorithm, or nil if it is not supported.
+func (c *digestChallenge) hash() func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(c.algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// isRenewed reports whether the challenge asks to retry with the new nonce
+// instead of rejecting the credentials authorized with nonce.
+func (c *digestChallenge) isRenewed(nonce string) bool {
+	return c != nil && (c.stale || c.nonce != nonce)
+}
+
+func (c *digestChallenge) isSession() bool {
+	return strings.HasSuffix(strings.ToLower(c.algorithm), "-sess")
+}
+
+// hasQop reports whether the challenge offers "qop=auth".
+// "auth-int" is not supported because the body may not be rewound.
+func (c *digestChallenge) hasQop() bool {
+	for _, qop := range c.qop {
+		if strings.EqualFold(qop, "auth") {
+			return true
+		}
+	}
+	return false
+}
+
+// A digestAuth holds the last challenge of the device to authorize requests
+// without being challenged every time.
+type digestAuth struct {
+	mu        sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+// update replaces the challenge with the new one.
+func (a *digestAuth) update(challenge *digestChallenge) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.challenge = challenge
+	a.nc = 0
+}
+
+// authorize returns the value of Authorization header for the request,
+// and the nonce used. ok is false if no challenge has been received yet.
+func (a *digestAuth) authorize(username, password, method, uri string) (token, nonce string, ok bool) {
+	a.mu.Lock()
+	challenge := a.challenge
+	a.nc++
+	nc := fmt.Sprintf("%08x", a.nc)
+	a.mu.Unlock()
+
+	if challenge == nil {
+		return "", "", false
+	}
+
+	h := func(s string) string {
+		hash := challenge.hash()()
+		hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	realm := challenge.realm
+	if realm == "" {
+		realm = digestAuthRealm
+	}
+
+	cnonce := newCnonce()
+	ha1 := h(username + ":" + realm + ":" + password)
+	if challenge.isSession() {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var response string
+	if challenge.hasQop() {
+		response = h(strings.Join([]string{ha1, challenge.nonce, nc, cnonce, "auth", ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	}
+
+	token = fmt.Sprintf(
+		"Digest username=\"%s\", realm=\"%s\", uri=\"%s\", nonce=\"%s\", response=\"%s\"",
+		username,
+		realm,
+		uri,
+		challenge.nonce,
+		response,
+	)
+	if challenge.algorithm != "" {
+		token += fmt.Sprintf(", algorithm=%s", challenge.algorithm)
+	}
+	if challenge.opaque != "" {
+		token += fmt.Sprintf(", opaque=\"%s\"", challenge.opaque)
+	}
+	if challenge.hasQop() {
+		token += fmt.Sprintf(", qop=auth, nc=%s, cnonce=\"%s\"", nc, cnonce)
+	}
+
+	return token, challenge.nonce, true
+}
+
+var newCnonce = func() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,123 @@
+package airplay
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseDigestChallenge(t *testing.T) {
+	header := `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, ` +
+		`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", stale=TRUE`
+
+	challenge, ok := parseDigestChallenge(header)
+	if !ok {
+		t.Fatal("Challenge should be parsed")
+	}
+
+	if challenge.realm != "http-auth@example.org" || challenge.algorithm != "SHA-256" || !challenge.stale ||
+		challenge.nonce != "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v" || challenge.opaque != "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS" {
+		t.Fatalf("Incorrect challenge (actual %+v)", challenge)
+	}
+
+	if len(challenge.qop) != 2 || !challenge.hasQop() {
+		t.Fatalf("Incorrect qop (actual %v)", challenge.qop)
+	}
+
+	for _, header := range []string{
+		`Basic realm="AirPlay"`,
+		`Digest realm="AirPlay"`,
+		`Digest realm="AirPlay", nonce="4444", algorithm=SHA-512-256`,
+	} {
+		if _, ok := parseDigestChallenge(header); ok {
+			t.Errorf("Challenge should not be parsed: %s", header)
+		}
+	}
+}
+
+func TestDigestAuthorize(t *testing.T) {
+	defer func(f func() string) { newCnonce = f }(newCnonce)
+
+	tests := []struct {
+		header   string
+		cnonce   string
+		username string
+		password string
+		method   string
+		uri      string
+		expect   string
+	}{
+		// RFC 2617 3.5
+		{
+			`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			"0a4f113b", "Mufasa", "Circle Of Life", "GET", "/dir/index.html",
+			`response="6629fae49393a05397450978507c4ef1", opaque="5ccc069c403ebaf9f0171e9517f40e41", qop=auth, nc=00000001, cnonce="0a4f113b"`,
+		},
+		// RFC 7616 3.9.1
+		{
+			`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			"f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "Mufasa", "Circle of Life", "GET", "/dir/index.html",
+			`response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", algorithm=SHA-256`,
+		},
+	}
+
+	for _, test := range tests {
+		challenge, ok := parseDigestChallenge(test.header)
+		if !ok {
+			t.Fatalf("Challenge should be parsed: %s", test.header)
+		}
+
+		auth := &digestAuth{}
+		auth.update(challenge)
+		newCnonce = func() string { return test.cnonce }
+
+		token, nonce, ok := auth.authorize(test.username, test.password, test.method, test.uri)
+		if !ok || nonce != challenge.nonce || !strings.Contains(token, test.expect) {
+			t.Fatalf("Incorrect authorization (expected %s, actual %s)", test.expect, token)
+		}
+	}
+}
+
+func TestClientWithCachedNonce(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"POST", "/stop"},
+		{"POST", "/stop"},
+		{"POST", "/stop"},
+		{"POST", "/stop"},
+		{"POST", "/stop"},
+	}
+	requestCount := 0
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		requestCount++
+		challenge, _ := parseDigestChallenge(req.Header.Get("Authorization"))
+
+		switch requestCount {
+		case 1:
+			w.Header().Set("WWW-Authenticate", `Digest realm="AirPlay", nonce="1111", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case 2, 3:
+			// The second Stop() is authorized with the cached nonce.
+			if challenge == nil || challenge.nonce != "1111" {
+				t.Fatalf("Request should be authorized with cached nonce (actual %s)", req.Header.Get("Authorization"))
+			}
+			if requestCount == 3 {
+				w.Header().Set("WWW-Authenticate", `Digest realm="AirPlay", nonce="2222", qop="auth", stale=true`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case 4:
+			if challenge == nil || challenge.nonce != "2222" {
+				t.Fatalf("Request should be retried with new nonce (actual %s)", req.Header.Get("Authorization"))
+			}
+		}
+	})
+
+	client := getTestClient(t, ts)
+	client.SetPassword("gongo")
+	for i := 0; i < 3; i++ {
+		if err := client.StopContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -3,10 +3,12 @@ package airplay
 // discovery.go was created in reference to github.com/armon/mdns/client.go
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/miekg/dns"
@@ -26,10 +28,10 @@ var (
 )
 
 type discovery struct {
-	mconn    *net.UDPConn
-	uconn    *net.UDPConn
-	closed   bool
-	closedCh chan int
+	mconn     *net.UDPConn
+	uconn     *net.UDPConn
+	closedCh  chan int
+	closeOnce sync.Once
 }
 
 type entry struct {
@@ -53,22 +55,34 @@ func newDiscovery() (*discovery, error) {
 
 	uconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
 	if err != nil {
+		mconn.Close()
 		return nil, err
 	}
 
 	d := &discovery{
 		mconn:    mconn,
 		uconn:    uconn,
-		closed:   false,
 		closedCh: make(chan int),
 	}
 	return d, nil
 }
 
-func searchEntry(params *queryParam) []*entry {
-	d, _ := newDiscovery()
+func searchEntry(ctx context.Context, params *queryParam) ([]*entry, error) {
+	d, err := newDiscovery()
+	if err != nil {
+		return nil, err
+	}
 	defer d.close()
 
+	// Close sockets as soon as ctx is done so that blocked reads return.
+	go func() {
+		select {
+		case <-ctx.Done():
+			d.close()
+		case <-d.closedCh:
+		}
+	}()
+
 	if params.timeout == 0 {
 		params.timeout = 1 * time.Second
 	}
@@ -77,10 +91,10 @@ func searchEntry(params *queryParam) []*entry {
 		params.maxCount = 5
 	}
 
-	return d.query(params)
+	return d.query(ctx, params)
 }
 
-func (d *discovery) query(params *queryParam) []*entry {
+func (d *discovery) query(ctx context.Context, params *queryParam) ([]*entry, error) {
 	// Send question
 	m := new(dns.Msg)
 	m.SetQuestion(searchDomain, dns.TypePTR)
@@ -92,7 +106,8 @@ func (d *discovery) query(params *queryParam) []*entry {
 	go d.receive(d.mconn, msgCh)
 
 	entries := []*entry{}
-	finish := time.After(params.timeout)
+	finish := time.NewTimer(params.timeout)
+	defer finish.Stop()
 
 L:
 	for {
@@ -113,29 +128,41 @@ L:
 			if len(entries) >= params.maxCount {
 				break L
 			}
-		case <-finish:
+		case <-finish.C:
 			break L
+		case <-ctx.Done():
+			return entries, ctx.Err()
 		}
 	}
 
-	return entries
+	return entries, nil
 }
 
 func (d *discovery) close() {
-	d.closed = true
-	close(d.closedCh)
-	d.uconn.Close()
-	d.mconn.Close()
+	d.closeOnce.Do(func() {
+		close(d.closedCh)
+		d.uconn.Close()
+		d.mconn.Close()
+	})
+}
+
+func (d *discovery) isClosed() bool {
+	select {
+	case <-d.closedCh:
+		return true
+	default:
+		return false
+	}
 }
 
 func (d *discovery) receive(l *net.UDPConn, ch chan *dns.Msg) {
 	buf := make([]byte, dns.DefaultMsgSize)
 
-	for !d.closed {
+	for !d.isClosed() {
 		n, _, err := l.ReadFromUDP(buf)
 		if err != nil {
 			// Ignore error that was occurred by Close() while blocked to read packet
-			if !d.closed {
+			if !d.isClosed() {
 				log.Printf("airplay: [ERR] Failed to receive packet: %v", err)
 			}
 			continue
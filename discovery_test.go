@@ -1,9 +1,11 @@
 package airplay
 
 import (
+	"context"
 	"log"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -71,3 +73,12 @@ func TestParseErrorWithoutRequireRecords(t *testing.T) {
 		t.Fatal("It should occurs [A not found] error")
 	}
 }
+
+func TestSearchEntryWithCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := searchEntry(ctx, &queryParam{timeout: time.Minute}); err != context.Canceled {
+		t.Fatalf("It should occurs [context canceled] error (actual %v)", err)
+	}
+}
@@ -1,11 +1,46 @@
 package airplay
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 
 	"github.com/miekg/dns"
 )
 
+var (
+	// ErrNoDevices is returned when no AirPlay device is found in LAN.
+	ErrNoDevices = errors.New("airplay: [ERR] AirPlay devices not found")
+
+	// ErrPasswordRequired is wrapped in *DeviceError when the device requires password but it is not set.
+	ErrPasswordRequired = errors.New("password is required")
+
+	// ErrWrongPassword is wrapped in *DeviceError when the device rejects the password.
+	ErrWrongPassword = errors.New("wrong password")
+
+	// ErrReverseUnavailable is wrapped in *RequestError when the reverse connection would bypass
+	// the custom HTTPClient or Transport of ClientParam without Dialer.
+	ErrReverseUnavailable = errors.New("reverse connection is not available with custom transport")
+
+	// ErrNotReadyTimeout is returned when content does not become ready to play in time.
+	ErrNotReadyTimeout = errors.New("airplay: [ERR] Timeout while waiting for ready to play")
+
+	// ErrPlaylistNotSupported is returned by the playlist methods when the device has no playlist of its own.
+	// Callers can fall back to Queue.
+	ErrPlaylistNotSupported = errors.New("airplay: [ERR] Playlist is not supported by the device")
+
+	// ErrQueueIndexOutOfRange is returned when the index is out of range of Queue.
+	ErrQueueIndexOutOfRange = errors.New("airplay: [ERR] Index is out of range of queue")
+
+	// ErrQueueNotRunning is returned by Next() and Previous() when Queue is not running.
+	ErrQueueNotRunning = errors.New("airplay: [ERR] Queue is not running")
+
+	// ErrQueueRunning is returned by Run() when Queue is already running.
+	ErrQueueRunning = errors.New("airplay: [ERR] Queue is already running")
+)
+
 type DNSResponseParseError struct {
 	Type    string
 	Records []dns.RR
@@ -25,3 +60,124 @@ func (e *DNSResponseParseError) Error() string {
 		e.Records,
 	)
 }
+
+// A RequestError is returned when a request to the AirPlay device fails.
+//
+// Err is a *StatusError when the device responded with non-2xx status code,
+// otherwise it describes the transport or authentication failure.
+type RequestError struct {
+	Method   string
+	Endpoint string
+	Err      error
+}
+
+func newRequestError(method, endpoint string, err error) *RequestError {
+	return &RequestError{
+		Method:   method,
+		Endpoint: endpoint,
+		Err:      err,
+	}
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf(
+		"airplay: [ERR] %s /%s: %v",
+		e.Method,
+		e.Endpoint,
+		e.Err,
+	)
+}
+
+// Unwrap returns the underlying error.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// A StatusError is returned when the AirPlay device responds with non-2xx status code.
+type StatusError struct {
+	StatusCode int
+	Status     string
+
+	// Endpoint is the path of the request, or the URL for requests to other than the device.
+	Endpoint string
+
+	// Body is the beginning of the response body.
+	Body []byte
+}
+
+// maxStatusErrorBody is the maximum size of StatusError.Body.
+const maxStatusErrorBody = 4096
+
+func newStatusError(endpoint string, response *http.Response) *StatusError {
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxStatusErrorBody))
+	return &StatusError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Endpoint:   endpoint,
+		Body:       body,
+	}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %s", e.Status)
+}
+
+// A DeviceError is returned when the AirPlay device is unreachable or rejects the client.
+//
+// Err is ErrPasswordRequired, ErrWrongPassword or the transport failure.
+type DeviceError struct {
+	Device Device
+	Err    error
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf(
+		"device %s:%d: %v",
+		e.Device.Addr,
+		e.Device.Port,
+		e.Err,
+	)
+}
+
+// Unwrap returns the underlying error.
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+// A PropertyError is returned when the device reports non-zero errorCode for getProperty or setProperty.
+type PropertyError struct {
+	Name      string
+	ErrorCode int
+}
+
+func (e *PropertyError) Error() string {
+	return fmt.Sprintf(
+		"airplay: [ERR] Property %s returned errorCode %d",
+		e.Name,
+		e.ErrorCode,
+	)
+}
+
+// isNotSupported reports whether err means the device does not support the request.
+func isNotSupported(err error) bool {
+	var propertyErr *PropertyError
+	if errors.As(err, &propertyErr) {
+		return true
+	}
+
+	switch statusCodeOf(err) {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// statusCodeOf returns the status code if err is caused by non-2xx response, otherwise 0.
+func statusCodeOf(err error) int {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return 0
+	}
+
+	return statusErr.StatusCode
+}
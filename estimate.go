@@ -0,0 +1,116 @@
+package airplay
+
+import (
+	"math"
+	"time"
+)
+
+// A PositionEstimate is the position of playing content interpolated from the last sample.
+type PositionEstimate struct {
+	// Position represents the estimated position (second).
+	Position float64
+
+	// Duration represents the length of content (second), or 0 if unknown.
+	Duration float64
+
+	// Rate represents the playback rate of the last sample.
+	Rate float64
+
+	// Age is how long ago the last sample was taken. The estimate is less accurate as it grows.
+	Age time.Duration
+}
+
+// A positionSample is the position known at the time.
+type positionSample struct {
+	position float64
+	duration float64
+	rate     float64
+	at       time.Time
+}
+
+// estimate returns the position interpolated at now.
+func (s *positionSample) estimate(now time.Time) PositionEstimate {
+	age := now.Sub(s.at)
+	position := s.position + s.rate*age.Seconds()
+	if s.duration > 0 {
+		position = math.Min(position, s.duration)
+	}
+
+	return PositionEstimate{
+		Position: math.Max(position, 0),
+		Duration: s.duration,
+		Rate:     s.rate,
+		Age:      age,
+	}
+}
+
+// EstimatedPosition returns the current position without requests to the device.
+//
+// It is interpolated from the last sample of GetPlaybackInfo() or Position(),
+// corrected by Scrub() and Rate() sent by the client.
+// ok is false if no content is known to be playing.
+func (c *Client) EstimatedPosition() (estimate PositionEstimate, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sample == nil {
+		return PositionEstimate{}, false
+	}
+	return c.sample.estimate(time.Now()), true
+}
+
+// samplePlaybackInfo replaces the sample with info.
+func (c *Client) samplePlaybackInfo(info *PlaybackInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !info.IsReadyToPlay {
+		c.sample = nil
+		return
+	}
+
+	c.sample = &positionSample{
+		position: info.Position,
+		duration: info.Duration,
+		rate:     info.Rate,
+		at:       time.Now(),
+	}
+}
+
+// samplePosition replaces the position of the sample, keeping the rate.
+// If duration is 0, the duration of the sample is kept.
+// It does nothing if there is no sample, since the rate is unknown.
+func (c *Client) samplePosition(position, duration float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sample == nil {
+		return
+	}
+
+	sample := *c.sample
+	sample.position = position
+	if duration > 0 {
+		sample.duration = duration
+	}
+	sample.at = time.Now()
+	c.sample = &sample
+}
+
+// sampleRate changes the rate of the sample from now.
+func (c *Client) sampleRate(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sample == nil {
+		return
+	}
+
+	now := time.Now()
+	c.sample = &positionSample{
+		position: c.sample.estimate(now).Position,
+		duration: c.sample.duration,
+		rate:     rate,
+		at:       now,
+	}
+}
@@ -0,0 +1,109 @@
+package airplay
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+func TestEstimatedPosition(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"GET", "/playback-info"},
+		{"POST", "/rate"},
+		{"POST", "/scrub"},
+		{"GET", "/scrub"},
+		{"GET", "/playback-info"},
+	}
+	infos := []map[string]interface{}{
+		{"readyToPlay": true, "duration": 36.0, "position": 18.0, "rate": 1.0},
+		{"readyToPlay": false},
+	}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/playback-info":
+			body, _ := plist.Marshal(infos[0], plist.XMLFormat)
+			infos = infos[1:]
+			w.Write(body)
+		case req.Method == "GET" && req.URL.Path == "/scrub":
+			w.Write([]byte("duration: 36.0\nposition: 12.0\n"))
+		}
+	})
+
+	client := getTestClient(t, ts)
+	if _, ok := client.EstimatedPosition(); ok {
+		t.Fatal("Position should be unknown before samples")
+	}
+
+	if _, err := client.GetPlaybackInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	// As if the sample was taken 2 seconds ago.
+	client.sample.at = client.sample.at.Add(-2 * time.Second)
+	estimate, ok := client.EstimatedPosition()
+	if !ok || estimate.Position < 20.0 || estimate.Position > 20.1 || estimate.Age < 2*time.Second || estimate.Duration != 36.0 {
+		t.Fatalf("Incorrect estimate (actual %+v)", estimate)
+	}
+
+	client.sample.at = client.sample.at.Add(-time.Minute)
+	if estimate, _ := client.EstimatedPosition(); estimate.Position != 36.0 {
+		t.Fatalf("Estimate should be clamped to duration (actual %f)", estimate.Position)
+	}
+
+	if err := client.Rate(0); err != nil {
+		t.Fatal(err)
+	}
+	client.sample.at = client.sample.at.Add(-time.Second)
+	if estimate, _ := client.EstimatedPosition(); estimate.Position != 36.0 || estimate.Rate != 0 {
+		t.Fatalf("Estimate should stop while paused (actual %+v)", estimate)
+	}
+
+	if err := client.Scrub(30.0); err != nil {
+		t.Fatal(err)
+	}
+	if estimate, _ := client.EstimatedPosition(); estimate.Position != 30.0 {
+		t.Fatalf("Estimate should be corrected by Scrub (actual %f)", estimate.Position)
+	}
+
+	if _, err := client.Position(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if estimate, _ := client.EstimatedPosition(); estimate.Position != 12.0 || estimate.Age > time.Second {
+		t.Fatalf("Estimate should be corrected by GET /scrub (actual %+v)", estimate)
+	}
+
+	if _, err := client.GetPlaybackInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.EstimatedPosition(); ok {
+		t.Fatal("Position should be unknown after playback ended")
+	}
+}
+
+func TestEstimatedPositionWithoutPlayback(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"POST", "/scrub"},
+		{"GET", "/scrub"},
+	}
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			w.Write([]byte("duration: 0.0\nposition: 0.0\n"))
+		}
+	})
+
+	client := getTestClient(t, ts)
+	if err := client.Scrub(30.0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Position(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if estimate, ok := client.EstimatedPosition(); ok {
+		t.Fatalf("Position should be unknown without playback (actual %+v)", estimate)
+	}
+}
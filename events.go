@@ -0,0 +1,131 @@
+package airplay
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// PlaybackState represents a state of content playback.
+type PlaybackState string
+
+const (
+	StateLoading PlaybackState = "loading"
+	StatePlaying PlaybackState = "playing"
+	StatePaused  PlaybackState = "paused"
+	StateStopped PlaybackState = "stopped"
+)
+
+// A PlaybackEvent is a change of playback state notified by the AirPlay device.
+type PlaybackEvent struct {
+	// Category represents the kind of content. e.g. "video", "photo".
+	Category string `plist:"category"`
+
+	// State represents the new playback state.
+	State PlaybackState `plist:"state"`
+}
+
+// Events subscribes to playback state changes.
+//
+// Events are pushed by the device over a reverse HTTP connection that belongs
+// to the same session as Play(). If the device does not support it,
+// Events falls back to polling playback informations.
+//
+// The returned channel is closed when ctx is done or the device is unreachable.
+func (c *Client) Events(ctx context.Context) <-chan PlaybackEvent {
+	ch := make(chan PlaybackEvent)
+
+	go func() {
+		defer close(ch)
+
+		reverse, err := c.connection.openReverse(ctx, reversePurposeEvent)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.pollEvents(ctx, ch)
+			}
+			return
+		}
+
+		reverse.serve(func(req *http.Request) *http.Response {
+			if req.Method != "POST" || req.URL.Path != "/event" {
+				return nil
+			}
+
+			event, err := decodePlaybackEvent(req)
+			if err != nil {
+				return newReverseResponse(http.StatusBadRequest, "", nil)
+			}
+
+			select {
+			case ch <- *event:
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}()
+
+	return ch
+}
+
+// pollEvents emulates events by polling playback informations.
+func (c *Client) pollEvents(ctx context.Context, ch chan<- PlaybackEvent) {
+	ticker := time.NewTicker(c.pollIntervalOrDefault())
+	defer ticker.Stop()
+
+	var state PlaybackState
+
+	for {
+		info, err := c.GetPlaybackInfoContext(ctx)
+		if err != nil {
+			return
+		}
+
+		next := playbackStateOf(info, state)
+
+		if next != state {
+			state = next
+			select {
+			case ch <- PlaybackEvent{Category: "video", State: state}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// playbackStateOf estimates the playback state from playback informations.
+func playbackStateOf(info *PlaybackInfo, previous PlaybackState) PlaybackState {
+	if !info.IsReadyToPlay {
+		if previous == StatePlaying || previous == StatePaused || previous == StateStopped {
+			return StateStopped
+		}
+		return StateLoading
+	}
+
+	if info.Rate == 0 {
+		return StatePaused
+	}
+
+	return StatePlaying
+}
+
+func decodePlaybackEvent(req *http.Request) (*PlaybackEvent, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &PlaybackEvent{}
+	if err := unmarshalPlist(body, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
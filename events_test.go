@@ -0,0 +1,129 @@
+package airplay
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eventXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>category</key>
+	<string>video</string>
+	<key>sessionID</key>
+	<integer>1</integer>
+	<key>state</key>
+	<string>%s</string>
+</dict>
+</plist>`
+
+// pausedPlaybackInfo is ready to play at rate 0.
+var pausedPlaybackInfo = strings.Replace(playingPlaybackInfo, "<real>1.00000</real>", "<real>0.00000</real>", 1)
+
+func TestEventsWithReverseConnection(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/reverse" {
+			t.Errorf("Unexpected request: %s %s", req.Method, req.URL.Path)
+			return
+		}
+
+		if req.Header.Get("Upgrade") != "PTTH/1.0" || req.Header.Get("X-Apple-Purpose") != "event" {
+			t.Errorf("Incorrect request header (actual %v)", req.Header)
+		}
+
+		if req.Header.Get("X-Apple-Session-ID") == "" {
+			t.Error("Not found X-Apple-Session-ID header")
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: PTTH/1.0\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+
+		reader := bufio.NewReader(conn)
+		for _, state := range []string{"loading", "playing", "stopped"} {
+			body := fmt.Sprintf(eventXML, state)
+			fmt.Fprintf(conn, "POST /event HTTP/1.1\r\nContent-Type: text/x-apple-plist+xml\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+
+			response, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("Incorrect response status (actual %d)", response.StatusCode)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	client := getTestClient(t, ts)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	expects := []PlaybackState{StateLoading, StatePlaying, StateStopped}
+	actuals := []PlaybackState{}
+	for event := range client.Events(ctx) {
+		if event.Category != "video" {
+			t.Fatalf("Incorrect event category (actual %s)", event.Category)
+		}
+		actuals = append(actuals, event.State)
+	}
+
+	if fmt.Sprint(actuals) != fmt.Sprint(expects) {
+		t.Fatalf("Incorrect events (actual %v)", actuals)
+	}
+}
+
+func TestEventsFallbackToPolling(t *testing.T) {
+	responseXMLs := []string{
+		stopPlaybackInfo,
+		playingPlaybackInfo,
+		playingPlaybackInfo,
+		pausedPlaybackInfo,
+		stopPlaybackInfo,
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/reverse":
+			w.WriteHeader(http.StatusNotFound)
+		case "/playback-info":
+			if len(responseXMLs) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			xml := responseXMLs[0]
+			responseXMLs = responseXMLs[1:]
+			w.Write([]byte(xml))
+		}
+	}))
+	defer ts.Close()
+
+	client := getTestClient(t, ts)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	expects := []PlaybackState{StateLoading, StatePlaying, StatePaused, StateStopped}
+	actuals := []PlaybackState{}
+	for event := range client.Events(ctx) {
+		actuals = append(actuals, event.State)
+	}
+
+	if fmt.Sprint(actuals) != fmt.Sprint(expects) {
+		t.Fatalf("Incorrect events (actual %v)", actuals)
+	}
+}
@@ -4,17 +4,42 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gongo/go-airplay"
 )
 
+// seconds is a flag value of the number of seconds (e.g. 90, 1.5) or a duration (e.g. 90s, 1m30s).
+type seconds time.Duration
+
+func (s *seconds) String() string {
+	return strconv.FormatFloat(time.Duration(*s).Seconds(), 'f', -1, 64)
+}
+
+func (s *seconds) Set(value string) error {
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		*s = seconds(f * float64(time.Second))
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*s = seconds(d)
+	return nil
+}
+
 var opts struct {
-	position     float64
+	position     seconds
+	delta        seconds
 	showHelpFlag bool
 }
 
 func init() {
-	flag.Float64Var(&opts.position, "p", 0.0, "Number of seconds to move (second)")
+	flag.Var(&opts.position, "p", "Position to seek from the beginning (second, or e.g. 1m30s)")
+	flag.Var(&opts.delta, "r", "Seconds to move from the current position, negative to rewind (second, or e.g. -30s)")
 	flag.BoolVar(&opts.showHelpFlag, "h", false, "Show this message")
 	flag.Parse()
 
@@ -29,6 +54,18 @@ func init() {
 }
 
 func main() {
-	client, _ := airplay.NewClient()
-	client.Scrub(opts.position)
+	client, err := airplay.FirstClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.delta != 0 {
+		err = client.SeekBy(time.Duration(opts.delta))
+	} else {
+		err = client.SeekTo(time.Duration(opts.position))
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
 }
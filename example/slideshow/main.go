@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
 	"time"
 
@@ -11,13 +12,21 @@ import (
 )
 
 var opts struct {
-	imagePath    string
-	showHelpFlag bool
+	theme         string
+	slideDuration time.Duration
+	playTimeout   time.Duration
+	showHelpFlag  bool
 }
 
 func init() {
-	flag.StringVar(&opts.imagePath, "i", "", "Input image path (local or remote)")
+	flag.StringVar(&opts.theme, "theme", "Classic", "Slideshow theme")
+	flag.DurationVar(&opts.slideDuration, "d", 3*time.Second, "Duration to show a slide")
+	flag.DurationVar(&opts.playTimeout, "t", 30*time.Second, "Timeout for slideshow to end")
 	flag.BoolVar(&opts.showHelpFlag, "h", false, "Show this message")
+	flag.Usage = func() {
+		log.Printf("Usage: %s [options] image.jpg...", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if opts.showHelpFlag {
@@ -25,31 +34,55 @@ func init() {
 		os.Exit(0)
 	}
 
-	if opts.imagePath == "" {
+	if flag.NArg() == 0 {
 		flag.Usage()
-		log.Fatal("options: Missing image path")
+		log.Fatal("options: Missing image paths")
 	}
 }
 
 func main() {
-	client, _ := airplay.NewClient()
-	rand.Seed(time.Now().UnixNano())
+	client, err := airplay.FirstClient()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	transitions := []airplay.SlideTransition{
-		airplay.SlideRight,
-		airplay.SlideLeft,
+	themes, err := client.SlideshowFeatures(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, theme := range themes {
+		log.Printf("Available theme: %s (%s)", theme.Key, theme.Name)
 	}
 
-	timeout := time.After(10 * time.Second)
-	interval := time.Tick(time.Second)
+	paths := flag.Args()
+	slideshow, err := airplay.NewSlideshow(client, &airplay.SlideshowParam{
+		Theme:         opts.theme,
+		SlideDuration: opts.slideDuration,
+		Assets: func(ctx context.Context, id int) ([]byte, error) {
+			return ioutil.ReadFile(paths[(id-1)%len(paths)])
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.playTimeout)
+	defer cancel()
+
+	if err := slideshow.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
 
 	for {
 		select {
-		case <-timeout:
+		case event, ok := <-slideshow.Events():
+			if !ok {
+				return
+			}
+			log.Printf("Slideshow is %s (last asset: %d)", event.State, event.LastAssetID)
+		case <-ctx.Done():
+			slideshow.Stop(context.Background())
 			return
-		case <-interval:
-			index := rand.Intn(len(transitions))
-			client.PhotoWithSlide(opts.imagePath, transitions[index])
 		}
 	}
 }
@@ -0,0 +1,301 @@
+package airplay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	// Decoders of pictures accepted by ImagePipeline.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// An ImageTransform transforms a picture in ImagePipeline. e.g. adds a watermark.
+type ImageTransform func(img image.Image) (image.Image, error)
+
+// An ImagePipeline prepares pictures before they are sent to the device.
+//
+// Pictures in PNG, GIF or JPEG are decoded, rotated by EXIF orientation,
+// downscaled to fit the display, transformed by Transforms and encoded to JPEG.
+// A JPEG picture that needs none of them is sent as it is.
+type ImagePipeline struct {
+	// MaxWidth and MaxHeight limit the size of picture. Each of them applies on its own.
+	// If zero, the display size of the device known by ServerInfo() or Info() is used.
+	MaxWidth  int
+	MaxHeight int
+
+	// Quality is the quality of JPEG encoding from 1 to 100. If zero, 90.
+	Quality int
+
+	// Transforms are applied in order after rotation and downscale.
+	Transforms []ImageTransform
+}
+
+// Process prepares a picture to fit in the display of width x height.
+// MaxWidth and MaxHeight take precedence over width and height.
+// A zero bound does not limit the size in that direction.
+func (p *ImagePipeline) Process(data []byte, width, height int) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if p.MaxWidth > 0 {
+		width = p.MaxWidth
+	}
+	if p.MaxHeight > 0 {
+		height = p.MaxHeight
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = exifOrientation(data)
+	}
+
+	// Size of picture after rotation.
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	fitW, fitH := fitSize(w, h, width, height)
+
+	if format == "jpeg" && orientation == 1 && fitW == w && fitH == h && len(p.Transforms) == 0 {
+		return data, nil
+	}
+
+	if fitW != w || fitH != h {
+		if orientation >= 5 {
+			img = resizeImage(img, fitH, fitW)
+		} else {
+			img = resizeImage(img, fitW, fitH)
+		}
+	}
+	img = orientImage(img, orientation)
+
+	for _, transform := range p.Transforms {
+		if img, err = transform(img); err != nil {
+			return nil, err
+		}
+	}
+
+	quality := p.Quality
+	if quality <= 0 {
+		quality = defaultJPEGQuality
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, flattenImage(img), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitSize returns the size of w x h scaled down to fit in maxW x maxH keeping aspect ratio.
+// If maxW or maxH is zero, the size is not limited in that direction.
+func fitSize(w, h, maxW, maxH int) (int, int) {
+	if maxW <= 0 {
+		maxW = w
+	}
+	if maxH <= 0 {
+		maxH = h
+	}
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+
+	if w*maxH > h*maxW {
+		return maxW, maxInt(1, h*maxW/w)
+	}
+	return maxInt(1, w*maxH/h), maxH
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// toRGBA converts img to *image.RGBA whose origin is (0, 0).
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// flattenImage composes img over white background because JPEG has no alpha channel.
+func flattenImage(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Over)
+	return rgba
+}
+
+// resizeImage scales img to w x h by averaging the source pixels of each destination pixel.
+func resizeImage(img image.Image, w, h int) *image.RGBA {
+	src := toRGBA(img)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for dy := 0; dy < h; dy++ {
+		y0, y1 := dy*sh/h, (dy+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for dx := 0; dx < w; dx++ {
+			x0, x1 := dx*sw/w, (dx+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				i := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// orientImage rotates and flips img according to EXIF orientation (1 to 8).
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counterclockwise
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
+
+// exifOrientation returns the orientation tag in EXIF of JPEG data, or 1 if not found.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan, end of image
+			return 1
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + size
+	}
+
+	return 1
+}
+
+// tiffOrientation returns the orientation tag (0x0112) in IFD0 of TIFF header.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
@@ -0,0 +1,172 @@
+package airplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts APP1 segment of EXIF orientation after SOI marker.
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = append(tiff, 0x00, 0x01) // 1 entry
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0) // no next IFD
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	result := append([]byte{}, data[:2]...)
+	result = append(result, app1...)
+	return append(result, data[2:]...)
+}
+
+func decodeSize(t *testing.T, data []byte) (string, int, int) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return format, config.Width, config.Height
+}
+
+func TestImagePipelineKeepsFittingJPEG(t *testing.T) {
+	data := testJPEG(t, 64, 32)
+	pipeline := &ImagePipeline{}
+
+	result, err := pipeline.Process(data, 1920, 1080)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(result, data) {
+		t.Fatal("JPEG that needs no preparation should be sent as it is")
+	}
+}
+
+func TestImagePipelineConvertsAndDownscales(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	buf := &bytes.Buffer{}
+	png.Encode(buf, img)
+
+	pipeline := &ImagePipeline{MaxWidth: 200, MaxHeight: 200}
+	result, err := pipeline.Process(buf.Bytes(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format, w, h := decodeSize(t, result); format != "jpeg" || w != 200 || h != 50 {
+		t.Fatalf("Incorrect picture (actual %s %dx%d)", format, w, h)
+	}
+}
+
+func TestImagePipelineWithMaxWidthOnly(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	buf := &bytes.Buffer{}
+	png.Encode(buf, img)
+
+	pipeline := &ImagePipeline{MaxWidth: 200}
+	result, err := pipeline.Process(buf.Bytes(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format, w, h := decodeSize(t, result); format != "jpeg" || w != 200 || h != 50 {
+		t.Fatalf("Incorrect picture (actual %s %dx%d)", format, w, h)
+	}
+}
+
+func TestImagePipelineAppliesOrientation(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	src.Set(0, 0, color.White) // top-left
+	buf := &bytes.Buffer{}
+	jpeg.Encode(buf, src, &jpeg.Options{Quality: 100})
+	data := withOrientation(buf.Bytes(), 6)
+
+	if orientation := exifOrientation(data); orientation != 6 {
+		t.Fatalf("Incorrect EXIF orientation (actual %d)", orientation)
+	}
+
+	pipeline := &ImagePipeline{}
+	result, err := pipeline.Process(data, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, w, h := decodeSize(t, result); w != 8 || h != 16 {
+		t.Fatalf("Picture should be rotated and downscaled (actual %dx%d)", w, h)
+	}
+
+	rotated := orientImage(src, 6)
+	if r, _, _, _ := rotated.At(31, 0).RGBA(); r != 0xFFFF {
+		t.Fatal("Top-left pixel should be moved to top-right by rotation")
+	}
+}
+
+func TestImagePipelineWithTransforms(t *testing.T) {
+	called := false
+	pipeline := &ImagePipeline{
+		Transforms: []ImageTransform{
+			func(img image.Image) (image.Image, error) {
+				called = true
+				return image.NewRGBA(image.Rect(0, 0, 10, 10)), nil
+			},
+		},
+	}
+
+	result, err := pipeline.Process(testJPEG(t, 64, 32), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, w, h := decodeSize(t, result); !called || w != 10 || h != 10 {
+		t.Fatalf("Transform should be applied (actual %dx%d)", w, h)
+	}
+}
+
+func TestShowPhotoWithImagePipeline(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"POST", "/photo"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if format, w, h := decodeSize(t, body); format != "jpeg" || w != 100 || h != 50 {
+			t.Fatalf("Incorrect picture (actual %s %dx%d)", format, w, h)
+		}
+	})
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, err := NewClient(&ClientParam{
+		Addr:          addr,
+		Port:          port,
+		ImagePipeline: &ImagePipeline{MaxWidth: 100, MaxHeight: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 400, 200)))
+
+	if err := client.ShowPhoto(context.Background(), PhotoFromBytes(buf.Bytes()), SlideNone); err != nil {
+		t.Fatal(err)
+	}
+}
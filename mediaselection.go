@@ -0,0 +1,101 @@
+package airplay
+
+import (
+	"context"
+)
+
+// PropertyMediaSelectionOptions is the name of property that reports
+// the media selection options of playing content.
+const PropertyMediaSelectionOptions = "mediaSelectionOptions"
+
+const actionTypeSelectMediaOption = "selectMediaOption"
+
+// MediaType represents the type of media selection option.
+type MediaType string
+
+const (
+	MediaTypeAudio         MediaType = "soun"
+	MediaTypeSubtitle      MediaType = "sbtl"
+	MediaTypeClosedCaption MediaType = "clcp"
+)
+
+// A MediaSelectionOption is an audio or subtitle track of playing content (e.g. a HLS rendition).
+type MediaSelectionOption struct {
+	// DisplayName represents the name of option to display.
+	DisplayName string `plist:"MediaSelectionOptionsName,omitempty"`
+
+	// Language represents the language as RFC 4646 language tag. e.g. "en", "ja".
+	Language string `plist:"MediaSelectionOptionsExtendedLanguageTag,omitempty"`
+
+	// UnicodeLanguage represents the language as unicode language identifier.
+	UnicodeLanguage string `plist:"MediaSelectionOptionsUnicodeLanguageIdentifier,omitempty"`
+
+	// MediaType represents the type of option.
+	MediaType MediaType `plist:"MediaSelectionOptionsMediaType,omitempty"`
+
+	// Characteristics represents the media characteristics. e.g. "public.accessibility.describes-video".
+	Characteristics []string `plist:"MediaSelectionOptionsTaggedMediaCharacteristics,omitempty"`
+}
+
+// selectMediaOptionAction is the body of POST /action to select media option.
+type selectMediaOptionAction struct {
+	Type   string                 `plist:"type"`
+	Params []MediaSelectionOption `plist:"params"`
+}
+
+// MediaSelectionOptions retrieves the audio and subtitle options of playing content that the device reports.
+func (c *Client) MediaSelectionOptions(ctx context.Context) ([]MediaSelectionOption, error) {
+	options := []MediaSelectionOption{}
+	if err := c.GetProperty(ctx, PropertyMediaSelectionOptions, &options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// SelectedMediaOptions retrieves the options currently selected.
+func (c *Client) SelectedMediaOptions(ctx context.Context) ([]MediaSelectionOption, error) {
+	options := []MediaSelectionOption{}
+	if err := c.GetProperty(ctx, PropertySelectedMediaArray, &options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// SelectMediaOption selects option in place of the selected option of the same media type.
+//
+// It sets selectedMediaArray, or falls back to POST /action if the device does not support it.
+func (c *Client) SelectMediaOption(ctx context.Context, option MediaSelectionOption) error {
+	return c.updateMediaSelection(ctx, option.MediaType, &option)
+}
+
+// DeselectMediaType deselects the option of mediaType. e.g. turns off subtitles.
+func (c *Client) DeselectMediaType(ctx context.Context, mediaType MediaType) error {
+	return c.updateMediaSelection(ctx, mediaType, nil)
+}
+
+func (c *Client) updateMediaSelection(ctx context.Context, mediaType MediaType, option *MediaSelectionOption) error {
+	selected, err := c.SelectedMediaOptions(ctx)
+	if err != nil && !isNotSupported(err) {
+		return err
+	}
+
+	options := []MediaSelectionOption{}
+	for _, s := range selected {
+		if s.MediaType != mediaType {
+			options = append(options, s)
+		}
+	}
+	if option != nil {
+		options = append(options, *option)
+	}
+
+	err = c.SetProperty(ctx, PropertySelectedMediaArray, options)
+	if !isNotSupported(err) {
+		return err
+	}
+
+	return c.sendPlist(ctx, "action", &selectMediaOptionAction{
+		Type:   actionTypeSelectMediaOption,
+		Params: options,
+	})
+}
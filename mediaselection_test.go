@@ -0,0 +1,119 @@
+package airplay
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/DHowett/go-plist"
+)
+
+func mediaOptionPlist(name, language, mediaType string) map[string]interface{} {
+	return map[string]interface{}{
+		"MediaSelectionOptionsName":                name,
+		"MediaSelectionOptionsExtendedLanguageTag": language,
+		"MediaSelectionOptionsMediaType":           mediaType,
+	}
+}
+
+func TestMediaSelectionOptions(t *testing.T) {
+	body, _ := plist.Marshal(map[string]interface{}{
+		"errorCode": uint64(0),
+		"value": []interface{}{
+			mediaOptionPlist("English", "en", "soun"),
+			mediaOptionPlist("Japanese", "ja", "soun"),
+			mediaOptionPlist("English", "en", "sbtl"),
+		},
+	}, plist.BinaryFormat)
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/getProperty"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.RawQuery != "mediaSelectionOptions" {
+			t.Fatalf("Incorrect property name (actual %s)", req.URL.RawQuery)
+		}
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+	options, err := client.MediaSelectionOptions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options) != 3 {
+		t.Fatalf("Incorrect options (actual %v)", options)
+	}
+
+	if options[1].Language != "ja" || options[1].MediaType != MediaTypeAudio || options[2].MediaType != MediaTypeSubtitle {
+		t.Fatalf("Incorrect options (actual %+v)", options)
+	}
+}
+
+func TestSelectMediaOption(t *testing.T) {
+	selected, _ := plist.Marshal(map[string]interface{}{
+		"errorCode": uint64(0),
+		"value": []interface{}{
+			mediaOptionPlist("English", "en", "soun"),
+			mediaOptionPlist("English", "en", "sbtl"),
+		},
+	}, plist.BinaryFormat)
+
+	expectRequests := []testExpectRequest{
+		{"GET", "/getProperty"},
+		{"PUT", "/setProperty"},
+	}
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/getProperty":
+			w.Write(selected)
+		case "/setProperty":
+			body, _ := ioutil.ReadAll(req.Body)
+			var params struct {
+				Value []MediaSelectionOption `plist:"value"`
+			}
+			if _, err := plist.Unmarshal(body, &params); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(params.Value) != 2 || params.Value[0].MediaType != MediaTypeSubtitle || params.Value[1].Language != "ja" {
+				t.Fatalf("Incorrect selectedMediaArray (actual %+v)", params.Value)
+			}
+		}
+	})
+
+	client := getTestClient(t, ts)
+	option := MediaSelectionOption{DisplayName: "Japanese", Language: "ja", MediaType: MediaTypeAudio}
+	if err := client.SelectMediaOption(context.Background(), option); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectMediaOptionFallbackToAction(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"GET", "/getProperty"},
+		{"PUT", "/setProperty"},
+		{"POST", "/action"},
+	}
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/getProperty", "/setProperty":
+			w.WriteHeader(http.StatusNotFound)
+		case "/action":
+			body, _ := ioutil.ReadAll(req.Body)
+			action := &selectMediaOptionAction{}
+			if _, err := plist.Unmarshal(body, action); err != nil {
+				t.Fatal(err)
+			}
+
+			if action.Type != "selectMediaOption" || len(action.Params) != 1 || action.Params[0].Language != "ja" {
+				t.Fatalf("Incorrect action (actual %+v)", action)
+			}
+		}
+	})
+
+	client := getTestClient(t, ts)
+	option := MediaSelectionOption{Language: "ja", MediaType: MediaTypeSubtitle}
+	if err := client.SelectMediaOption(context.Background(), option); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,231 @@
+package airplay
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// mediaTypes is the MIME types of media files, which are not registered on some systems.
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/x-m4v",
+	".mov":  "video/quicktime",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".wav":  "audio/wav",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+}
+
+// A MediaServer serves local files to AirPlay devices over HTTP.
+//
+// Files are served under unguessable tokens only while they are registered,
+// and support byte ranges and HEAD requests that devices use to seek.
+type MediaServer struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu    sync.Mutex
+	files map[string]string // token -> path
+}
+
+// NewMediaServer starts a media server listening on addr (e.g. "192.0.2.10:0").
+//
+// addr should be on the interface reachable from the devices.
+func NewMediaServer(addr string) (*MediaServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MediaServer{
+		listener: listener,
+		files:    map[string]string{},
+	}
+	s.server = &http.Server{Handler: s}
+	go s.server.Serve(listener)
+
+	return s, nil
+}
+
+// newMediaServerFor starts a media server on the interface that routes to device.
+func newMediaServerFor(device Device) (*MediaServer, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(device.Addr, fmt.Sprint(device.Port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	host, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMediaServer(net.JoinHostPort(host, "0"))
+}
+
+// URL returns the base URL of the server.
+func (s *MediaServer) URL() string {
+	return "http://" + s.listener.Addr().String() + "/"
+}
+
+// Register makes the local file of path available and returns its URL.
+func (s *MediaServer) Register(filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("airplay: [ERR] %s is a directory", filePath)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.files[token] = absPath
+	s.mu.Unlock()
+
+	// The file name is kept so that devices can guess the format from the extension.
+	return s.URL() + token + "/" + url.PathEscape(filepath.Base(absPath)), nil
+}
+
+// Unregister makes the file of fileURL returned by Register() unavailable.
+func (s *MediaServer) Unregister(fileURL string) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.files, tokenOf(u.Path))
+	s.mu.Unlock()
+}
+
+// Close stops the server.
+func (s *MediaServer) Close() error {
+	return s.server.Close()
+}
+
+// ServeHTTP serves a registered file.
+func (s *MediaServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	filePath, ok := s.files[tokenOf(req.URL.Path)]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if mediaType := mediaTypeOf(filePath); mediaType != "" {
+		w.Header().Set("Content-Type", mediaType)
+	}
+	http.ServeContent(w, req, filepath.Base(filePath), info.ModTime(), f)
+}
+
+func tokenOf(urlPath string) string {
+	return strings.SplitN(strings.TrimPrefix(path.Clean(urlPath), "/"), "/", 2)[0]
+}
+
+func mediaTypeOf(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if mediaType, ok := mediaTypes[ext]; ok {
+		return mediaType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// PlayFile start playback of local file.
+//
+// The file is served by the media server of the client while playing.
+// Returned channel is the same as Play().
+func (c *Client) PlayFile(path string) <-chan error {
+	return c.PlayFileContext(context.Background(), path)
+}
+
+// PlayFileContext is like PlayFile but stops playback monitoring when ctx is done.
+func (c *Client) PlayFileContext(ctx context.Context, path string) <-chan error {
+	ch := make(chan error, 1)
+
+	server, err := c.mediaServer()
+	if err != nil {
+		ch <- err
+		return ch
+	}
+
+	fileURL, err := server.Register(path)
+	if err != nil {
+		ch <- err
+		return ch
+	}
+
+	go func() {
+		err := <-c.PlayContext(ctx, fileURL)
+		server.Unregister(fileURL)
+		ch <- err
+	}()
+
+	return ch
+}
+
+// mediaServer returns ClientParam.MediaServer, or starts one owned by the client.
+func (c *Client) mediaServer() (*MediaServer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.media != nil {
+		return c.media, nil
+	}
+
+	server, err := newMediaServerFor(c.connection.device)
+	if err != nil {
+		return nil, err
+	}
+
+	c.media = server
+	c.ownsMedia = true
+	return server, nil
+}
@@ -0,0 +1,131 @@
+package airplay
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gongo/text-parameters"
+)
+
+func tempMediaFile(t *testing.T, name, content string) (string, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "mediaserver_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestMediaServer(t *testing.T) {
+	path, cleanup := tempMediaFile(t, "go.mp4", "0123456789")
+	defer cleanup()
+
+	server, err := NewMediaServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	fileURL, err := server.Register(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(fileURL, server.URL()) || !strings.HasSuffix(fileURL, "/go.mp4") {
+		t.Fatalf("Incorrect URL (actual %s)", fileURL)
+	}
+
+	req, _ := http.NewRequest("GET", fileURL, nil)
+	req.Header.Set("Range", "bytes=2-5")
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusPartialContent || string(body) != "2345" {
+		t.Fatalf("Incorrect range response (actual %d %s)", response.StatusCode, body)
+	}
+
+	if response.Header.Get("Content-Type") != "video/mp4" {
+		t.Fatalf("Incorrect Content-Type (actual %s)", response.Header.Get("Content-Type"))
+	}
+
+	response, err = http.Head(fileURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || response.ContentLength != 10 || response.Header.Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("Incorrect HEAD response (actual %d, %d)", response.StatusCode, response.ContentLength)
+	}
+
+	if response, _ := http.Get(server.URL() + "0123/go.mp4"); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("Unknown token should not be served (actual %d)", response.StatusCode)
+	}
+
+	server.Unregister(fileURL)
+	if response, _ := http.Get(fileURL); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("Unregistered file should not be served (actual %d)", response.StatusCode)
+	}
+}
+
+func TestPlayFile(t *testing.T) {
+	path, cleanup := tempMediaFile(t, "go.mp4", "movie")
+	defer cleanup()
+
+	var fileURL string
+	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
+		{"POST", "/play"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+	}
+	responseXMLs := []string{playingPlaybackInfo, stopPlaybackInfo}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/play":
+			u := &playbackInfoParam{}
+			parameters.NewDecorder(req.Body).Decode(u)
+			fileURL = u.Location
+
+			response, err := http.Get(fileURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+
+			if string(body) != "movie" {
+				t.Fatalf("Incorrect media (actual %s)", body)
+			}
+		case "/playback-info":
+			xml := responseXMLs[0]
+			responseXMLs = responseXMLs[1:]
+			w.Write([]byte(xml))
+		}
+	})
+
+	client := getTestClient(t, ts)
+	defer client.Close()
+
+	if err := <-client.PlayFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if response, _ := http.Get(fileURL); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("File should be unregistered after playback (actual %d)", response.StatusCode)
+	}
+}
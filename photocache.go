@@ -0,0 +1,129 @@
+package airplay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const (
+	assetActionCacheOnly     = "cacheOnly"
+	assetActionDisplayCached = "displayCached"
+)
+
+// A cachedPhoto is a picture preloaded to the device.
+type cachedPhoto struct {
+	src  PhotoSource
+	held bool // whether the device is considered to hold the picture
+}
+
+// PreloadPhoto uploads a JPEG picture to the device's cache without showing it.
+//
+// The picture can be shown later by ShowCachedPhoto() with key. src is the same as Photo().
+func (c *Client) PreloadPhoto(key, src string) error {
+	return c.PreloadPhotoContext(context.Background(), key, src)
+}
+
+// PreloadPhotoContext is like PreloadPhoto but cancels the requests when ctx is done.
+func (c *Client) PreloadPhotoContext(ctx context.Context, key, src string) error {
+	return c.PreloadPhotoSource(ctx, key, c.photoFromPath(src))
+}
+
+// PreloadPhotoSource is like PreloadPhotoContext but the picture is provided by src.
+func (c *Client) PreloadPhotoSource(ctx context.Context, key string, src PhotoSource) error {
+	c.mu.Lock()
+	if c.cachedPhotos == nil {
+		c.cachedPhotos = map[string]*cachedPhoto{}
+	}
+	photo := &cachedPhoto{src: src}
+	c.cachedPhotos[key] = photo
+	c.mu.Unlock()
+
+	return c.uploadCachedPhoto(ctx, key, photo)
+}
+
+// ShowCachedPhoto shows the picture preloaded by PreloadPhoto() in the transition specified.
+//
+// If the device no longer holds the picture, it is uploaded again.
+func (c *Client) ShowCachedPhoto(key string, transition SlideTransition) error {
+	return c.ShowCachedPhotoContext(context.Background(), key, transition)
+}
+
+// ShowCachedPhotoContext is like ShowCachedPhoto but cancels the requests when ctx is done.
+func (c *Client) ShowCachedPhotoContext(ctx context.Context, key string, transition SlideTransition) error {
+	c.mu.Lock()
+	photo, ok := c.cachedPhotos[key]
+	held := ok && photo.held
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("airplay: [ERR] Photo %q is not preloaded", key)
+	}
+
+	if !held {
+		if err := c.uploadCachedPhoto(ctx, key, photo); err != nil {
+			return err
+		}
+	}
+
+	err := c.displayCachedPhoto(ctx, key, transition)
+	if statusCodeOf(err) != http.StatusPreconditionFailed {
+		return err
+	}
+
+	// Cache miss: the device has evicted the picture.
+	if err := c.uploadCachedPhoto(ctx, key, photo); err != nil {
+		return err
+	}
+	return c.displayCachedPhoto(ctx, key, transition)
+}
+
+// CachedPhotoKeys returns the keys of pictures that the device is considered to hold.
+func (c *Client) CachedPhotoKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := []string{}
+	for key, photo := range c.cachedPhotos {
+		if photo.held {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (c *Client) uploadCachedPhoto(ctx context.Context, key string, photo *cachedPhoto) error {
+	c.setPhotoHeld(photo, false)
+
+	data, err := c.readPhoto(ctx, photo.src)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{
+		"X-Apple-AssetKey":    {key},
+		"X-Apple-AssetAction": {assetActionCacheOnly},
+	}
+	if err := c.send(ctx, "photo", bytes.NewReader(data), header); err != nil {
+		return err
+	}
+
+	c.setPhotoHeld(photo, true)
+	return nil
+}
+
+func (c *Client) displayCachedPhoto(ctx context.Context, key string, transition SlideTransition) error {
+	header := http.Header{
+		"X-Apple-AssetKey":    {key},
+		"X-Apple-AssetAction": {assetActionDisplayCached},
+		"X-Apple-Transition":  {string(transition)},
+	}
+	return c.send(ctx, "photo", nil, header)
+}
+
+func (c *Client) setPhotoHeld(photo *cachedPhoto, held bool) {
+	c.mu.Lock()
+	photo.held = held
+	c.mu.Unlock()
+}
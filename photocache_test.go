@@ -0,0 +1,82 @@
+package airplay
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreloadAndShowCachedPhoto(t *testing.T) {
+	remoteTs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("remotefile"))
+	}))
+	defer remoteTs.Close()
+
+	expectRequests := []testExpectRequest{
+		{"POST", "/photo"}, // cacheOnly
+		{"POST", "/photo"}, // displayCached
+		{"POST", "/photo"}, // displayCached (cache miss)
+		{"POST", "/photo"}, // cacheOnly
+		{"POST", "/photo"}, // displayCached
+	}
+	expectActions := []string{"cacheOnly", "displayCached", "displayCached", "cacheOnly", "displayCached"}
+	requestCount := 0
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		action := req.Header.Get("X-Apple-AssetAction")
+		if action != expectActions[requestCount] {
+			t.Fatalf("Incorrect X-Apple-AssetAction (actual %s)", action)
+		}
+
+		if req.Header.Get("X-Apple-AssetKey") != "gopher" {
+			t.Fatalf("Incorrect X-Apple-AssetKey (actual %s)", req.Header.Get("X-Apple-AssetKey"))
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		switch action {
+		case "cacheOnly":
+			if string(body) != "remotefile" {
+				t.Fatalf("Incorrect request body (actual %s)", body)
+			}
+		case "displayCached":
+			if len(body) != 0 {
+				t.Fatalf("Request body should be empty (actual %s)", body)
+			}
+
+			if req.Header.Get("X-Apple-Transition") != "Dissolve" {
+				t.Fatalf("Incorrect X-Apple-Transition (actual %s)", req.Header.Get("X-Apple-Transition"))
+			}
+
+			if requestCount == 2 {
+				w.WriteHeader(http.StatusPreconditionFailed)
+			}
+		}
+
+		requestCount++
+	})
+
+	client := getTestClient(t, ts)
+	if err := client.PreloadPhoto("gopher", remoteTs.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := client.CachedPhotoKeys(); len(keys) != 1 || keys[0] != "gopher" {
+		t.Fatalf("Incorrect cached photo keys (actual %v)", keys)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := client.ShowCachedPhoto("gopher", SlideDissolve); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestShowCachedPhotoWithoutPreload(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{}, nil)
+	client := getTestClient(t, ts)
+
+	if err := client.ShowCachedPhoto("unknown", SlideNone); err == nil {
+		t.Fatal("It should occurs [not preloaded] error")
+	}
+}
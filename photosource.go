@@ -0,0 +1,218 @@
+package airplay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFetchTimeout = 30 * time.Second
+	defaultFetchMaxSize = 50 << 20
+	defaultJPEGQuality  = 90
+)
+
+// A PhotoSource provides a picture shown on the device.
+type PhotoSource interface {
+	// ReadPhoto returns the encoded picture.
+	ReadPhoto(ctx context.Context) ([]byte, error)
+}
+
+// A FetchParam is the safeguards for fetching remote pictures.
+type FetchParam struct {
+	// Client is used to fetch. If nil, http.DefaultClient.
+	Client *http.Client
+
+	// Timeout limits the time to fetch. If zero, 30 seconds.
+	Timeout time.Duration
+
+	// MaxSize limits the size of picture in bytes. If zero, 50 MiB.
+	MaxSize int64
+
+	// ContentTypes is the allowed media types (e.g. "image/jpeg") or their prefixes (e.g. "image/").
+	// If empty, any content type is allowed.
+	ContentTypes []string
+}
+
+// PhotoFromBytes returns the picture of data.
+func PhotoFromBytes(data []byte) PhotoSource {
+	return bytesSource(data)
+}
+
+type bytesSource []byte
+
+func (s bytesSource) ReadPhoto(ctx context.Context) ([]byte, error) {
+	return s, nil
+}
+
+// PhotoFromReader returns the picture read from r.
+//
+// r is read only once at the first use, and the data is kept for reuse (e.g. re-upload to cache).
+func PhotoFromReader(r io.Reader) PhotoSource {
+	return &readerSource{reader: r}
+}
+
+type readerSource struct {
+	reader io.Reader
+	once   sync.Once
+	data   []byte
+	err    error
+}
+
+func (s *readerSource) ReadPhoto(ctx context.Context) ([]byte, error) {
+	s.once.Do(func() {
+		s.data, s.err = ioutil.ReadAll(s.reader)
+	})
+	return s.data, s.err
+}
+
+// PhotoFromImage returns the picture of img encoded to JPEG.
+func PhotoFromImage(img image.Image) PhotoSource {
+	return imageSource{image: img}
+}
+
+type imageSource struct {
+	image image.Image
+}
+
+func (s imageSource) ReadPhoto(ctx context.Context) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, s.image, &jpeg.Options{Quality: defaultJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PhotoFromFile returns the picture of local file.
+func PhotoFromFile(path string) PhotoSource {
+	return fileSource(path)
+}
+
+type fileSource string
+
+func (s fileSource) ReadPhoto(ctx context.Context) ([]byte, error) {
+	return ioutil.ReadFile(string(s))
+}
+
+// PhotoFromURL returns the picture of URL.
+//
+// "http" and "https" URLs are fetched with the safeguards of params (nil means defaults),
+// and "file" URLs are read from local file.
+func PhotoFromURL(rawurl string, params *FetchParam) PhotoSource {
+	if params == nil {
+		params = &FetchParam{}
+	}
+	return &urlSource{url: rawurl, params: *params}
+}
+
+type urlSource struct {
+	url    string
+	params FetchParam
+}
+
+func (s *urlSource) ReadPhoto(ctx context.Context) ([]byte, error) {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		return fileSource(u.Path).ReadPhoto(ctx)
+	case "http", "https":
+		return s.fetch(ctx)
+	}
+
+	return nil, fmt.Errorf("airplay: [ERR] Unsupported scheme of photo URL %q", s.url)
+}
+
+func (s *urlSource) fetch(ctx context.Context) ([]byte, error) {
+	client := s.params.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	timeout := s.params.Timeout
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+
+	maxSize := s.params.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultFetchMaxSize
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequest("GET", s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, newStatusError(s.url, response)
+	}
+
+	if !s.isAllowedContentType(response.Header.Get("Content-Type")) {
+		return nil, fmt.Errorf("airplay: [ERR] Unexpected content type %q of %s", response.Header.Get("Content-Type"), s.url)
+	}
+
+	if response.ContentLength > maxSize {
+		return nil, fmt.Errorf("airplay: [ERR] Photo %s exceeds %d bytes", s.url, maxSize)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("airplay: [ERR] Photo %s exceeds %d bytes", s.url, maxSize)
+	}
+
+	return data, nil
+}
+
+func (s *urlSource) isAllowedContentType(contentType string) bool {
+	if len(s.params.ContentTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range s.params.ContentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// photoFromPath returns the picture of local file path or URL that Photo() accepts.
+func photoFromPath(path string, params *FetchParam) PhotoSource {
+	u, err := url.Parse(path)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file") {
+		return PhotoFromURL(path, params)
+	}
+	return PhotoFromFile(path)
+}
@@ -0,0 +1,124 @@
+package airplay
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhotoFromReaderCanBeReadTwice(t *testing.T) {
+	src := PhotoFromReader(strings.NewReader("reader"))
+
+	for i := 0; i < 2; i++ {
+		data, err := src.ReadPhoto(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "reader" {
+			t.Fatalf("Incorrect data (actual %s)", data)
+		}
+	}
+}
+
+func TestPhotoFromImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	img.Set(0, 0, color.White)
+
+	data, err := PhotoFromImage(img).ReadPhoto(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Bounds().Dx() != 16 || decoded.Bounds().Dy() != 8 {
+		t.Fatalf("Incorrect size of image (actual %v)", decoded.Bounds())
+	}
+}
+
+func TestPhotoFromFileURL(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "photo_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("localfile")
+	f.Close()
+
+	data, err := PhotoFromURL("file://"+f.Name(), nil).ReadPhoto(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "localfile" {
+		t.Fatalf("Incorrect data (actual %s)", data)
+	}
+}
+
+func TestPhotoFromURLWithSafeguards(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/large.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write(bytes.Repeat([]byte("x"), 1024))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html></html>"))
+		case "/slow.jpg":
+			time.Sleep(100 * time.Millisecond)
+			w.Header().Set("Content-Type", "image/jpeg")
+		case "/small.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("small"))
+		}
+	}))
+	defer ts.Close()
+
+	params := &FetchParam{
+		Timeout:      10 * time.Millisecond,
+		MaxSize:      512,
+		ContentTypes: []string{"image/"},
+	}
+
+	for _, path := range []string{"/large.jpg", "/page.html", "/slow.jpg"} {
+		if _, err := PhotoFromURL(ts.URL+path, params).ReadPhoto(context.Background()); err == nil {
+			t.Errorf("It should occurs error for %s", path)
+		}
+	}
+
+	data, err := PhotoFromURL(ts.URL+"/small.jpg", params).ReadPhoto(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "small" {
+		t.Fatalf("Incorrect data (actual %s)", data)
+	}
+}
+
+func TestShowPhoto(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"POST", "/photo"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if string(body) != "inmemory" {
+			t.Fatalf("Incorrect request body (actual %s)", body)
+		}
+	})
+
+	client := getTestClient(t, ts)
+	if err := client.ShowPhoto(context.Background(), PhotoFromBytes([]byte("inmemory")), SlideNone); err != nil {
+		t.Fatal(err)
+	}
+}
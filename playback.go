@@ -0,0 +1,316 @@
+package airplay
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultPollInterval is the interval of polling playback informations.
+	defaultPollInterval = time.Second
+
+	// defaultReadyTimeout is the timeout of waiting for content to become ready to play.
+	defaultReadyTimeout = 10 * time.Second
+
+	// endOfContentTolerance is how close the position must be to the duration to be the end.
+	endOfContentTolerance = 0.5
+)
+
+// EndDetection represents how the end of playback is detected.
+type EndDetection int
+
+const (
+	// EndWhenNotReady detects the end when content is no longer ready to play.
+	EndWhenNotReady EndDetection = iota
+
+	// EndAtDuration also detects the end when the position reaches the duration,
+	// for devices that keep content ready to play after the end.
+	EndAtDuration
+)
+
+// PlaybackOptions are the options of PlayWithOptions().
+type PlaybackOptions struct {
+	// PollInterval is the interval of polling playback informations.
+	// By default, ClientParam.PollInterval or 1 second.
+	PollInterval time.Duration
+
+	// ReadyTimeout is the timeout of waiting for content to become ready to play.
+	// By default, ClientParam.ReadyTimeout or 10 seconds.
+	ReadyTimeout time.Duration
+
+	// StartPosition is the start position between 0 (0%) to 1 (100%), same as PlayAt().
+	StartPosition float64
+
+	// StartTime is the start position from the beginning, same as PlayFrom().
+	// If non-zero, StartPosition is ignored.
+	StartTime time.Duration
+
+	// EndDetection is how the end of playback is detected. By default, EndWhenNotReady.
+	EndDetection EndDetection
+
+	// Watchdog, if set, watches buffering and stall of playback, and recovers from stall.
+	Watchdog *Watchdog
+}
+
+// PlaybackEndReason represents why playback has ended.
+type PlaybackEndReason int
+
+const (
+	// PlaybackFinished means the position reached the duration.
+	PlaybackFinished PlaybackEndReason = iota
+
+	// PlaybackStoppedByReceiver means playback was stopped on the device. e.g. by the remote.
+	PlaybackStoppedByReceiver
+
+	// PlaybackPreempted means another content was played by other client (or other Play()).
+	PlaybackPreempted
+
+	// PlaybackStoppedBySender means playback was stopped by Stop().
+	PlaybackStoppedBySender
+
+	// PlaybackFailed means playback monitoring failed with PlaybackResult.Err.
+	PlaybackFailed
+)
+
+func (r PlaybackEndReason) String() string {
+	switch r {
+	case PlaybackFinished:
+		return "finished"
+	case PlaybackStoppedByReceiver:
+		return "stopped by receiver"
+	case PlaybackPreempted:
+		return "preempted"
+	case PlaybackStoppedBySender:
+		return "stopped by sender"
+	case PlaybackFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("PlaybackEndReason(%d)", int(r))
+}
+
+// A PlaybackResult is the result of playback.
+type PlaybackResult struct {
+	Reason PlaybackEndReason
+
+	// Err is the failure when Reason is PlaybackFailed, including ctx.Err().
+	Err error
+
+	// Position and Duration are the last known position and duration (second).
+	Position float64
+	Duration float64
+}
+
+// PlayWithOptions start content playback with opts.
+// If opts is nil, it is the same as PlayContext().
+//
+// Returned channel is the same as PlayContext().
+func (c *Client) PlayWithOptions(ctx context.Context, url string, opts *PlaybackOptions) <-chan error {
+	ch := make(chan error, 1)
+	results := c.PlayWithResult(ctx, url, opts)
+
+	go func() {
+		ch <- (<-results).Err
+	}()
+
+	return ch
+}
+
+// PlayWithResult is like PlayWithOptions but sends why playback has ended on the returned channel.
+func (c *Client) PlayWithResult(ctx context.Context, url string, opts *PlaybackOptions) <-chan PlaybackResult {
+	ch := make(chan PlaybackResult, 1)
+	opts = c.playbackOptions(opts)
+
+	go func() {
+		req := &playRequest{ContentLocation: url, StartPosition: opts.StartPosition}
+		if opts.StartTime != 0 {
+			req.StartPosition = 0
+			req.StartPositionSeconds = opts.StartTime.Seconds()
+		}
+
+		if err := c.play(ctx, req); err != nil {
+			ch <- PlaybackResult{Reason: PlaybackFailed, Err: err}
+			return
+		}
+
+		if interval := c.feedback(); interval > 0 {
+			c.connection.startFeedback(interval)
+			defer c.connection.stopFeedback()
+		}
+
+		ready, err := c.waitForReadyToPlay(ctx, opts)
+		if err != nil {
+			ch <- PlaybackResult{Reason: PlaybackFailed, Err: err}
+			return
+		}
+
+		ch <- c.monitorPlayback(ctx, req, ready, opts)
+	}()
+
+	return ch
+}
+
+// monitorPlayback polls playback informations until playback of req ends.
+// ready is the playback information when content became ready to play.
+func (c *Client) monitorPlayback(ctx context.Context, req *playRequest, ready *PlaybackInfo, opts *PlaybackOptions) PlaybackResult {
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	result := PlaybackResult{Position: ready.Position, Duration: ready.Duration}
+	deviceUUID := ready.UUID
+
+	if opts.Watchdog != nil {
+		opts.Watchdog.reset()
+	}
+
+	for {
+		info, err := c.GetPlaybackInfoContext(ctx)
+		if err != nil {
+			result.Reason, result.Err = PlaybackFailed, err
+			return result
+		}
+
+		// Devices report their own uuid unless it is given by /play,
+		// so the first one seen is compared with later ones.
+		// It is compared only while ready to play, because the uuid reported
+		// after the end of content may differ as well.
+		if deviceUUID == "" && info.IsReadyToPlay {
+			deviceUUID = info.UUID
+		}
+		uuidChanged := info.IsReadyToPlay && deviceUUID != "" && info.UUID != "" && info.UUID != deviceUUID
+
+		switch {
+		case c.isPreempted(req.UUID) || uuidChanged:
+			result.Reason = PlaybackPreempted
+			return result
+		case c.isStoppedBySender(req.UUID):
+			if info.IsReadyToPlay {
+				result.Position, result.Duration = info.Position, info.Duration
+			}
+			result.Reason = PlaybackStoppedBySender
+			return result
+		case opts.isEnded(info):
+			if info.IsReadyToPlay {
+				result.Position, result.Duration = info.Position, info.Duration
+			}
+			result.Reason = PlaybackStoppedByReceiver
+			if opts.isFinished(result.Position, result.Duration) {
+				result.Reason = PlaybackFinished
+			}
+			return result
+		}
+
+		result.Position, result.Duration = info.Position, info.Duration
+
+		if opts.Watchdog != nil && opts.Watchdog.observe(info, time.Now()) {
+			ready, err := c.restart(ctx, req, info.Position, opts)
+			if err != nil {
+				result.Reason, result.Err = PlaybackFailed, err
+				return result
+			}
+			deviceUUID = ready.UUID
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Reason, result.Err = PlaybackFailed, ctx.Err()
+			return result
+		case <-ticker.C:
+		}
+	}
+}
+
+// restart plays req again at position (second) to recover from stall.
+func (c *Client) restart(ctx context.Context, req *playRequest, position float64, opts *PlaybackOptions) (*PlaybackInfo, error) {
+	req.StartPosition = 0
+	req.StartPositionSeconds = position
+
+	if err := c.play(ctx, req); err != nil {
+		return nil, err
+	}
+	return c.waitForReadyToPlay(ctx, opts)
+}
+
+func (c *Client) isPreempted(uuid string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.playingUUID != uuid
+}
+
+func (c *Client) isStoppedBySender(uuid string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.playingUUID == uuid && c.stoppedBySender
+}
+
+// playbackOptions returns a copy of opts filled with the defaults of the client.
+func (c *Client) playbackOptions(opts *PlaybackOptions) *PlaybackOptions {
+	filled := PlaybackOptions{}
+	if opts != nil {
+		filled = *opts
+	}
+
+	if filled.PollInterval <= 0 {
+		filled.PollInterval = c.pollIntervalOrDefault()
+	}
+
+	if filled.ReadyTimeout <= 0 {
+		filled.ReadyTimeout = c.readyTimeout
+	}
+	if filled.ReadyTimeout <= 0 {
+		filled.ReadyTimeout = defaultReadyTimeout
+	}
+
+	return &filled
+}
+
+func (c *Client) pollIntervalOrDefault() time.Duration {
+	if c.pollInterval > 0 {
+		return c.pollInterval
+	}
+	return defaultPollInterval
+}
+
+func (opts *PlaybackOptions) isEnded(info *PlaybackInfo) bool {
+	if !info.IsReadyToPlay {
+		return true
+	}
+
+	return opts.EndDetection == EndAtDuration &&
+		info.Duration > 0 && info.Position >= info.Duration-endOfContentTolerance
+}
+
+// isFinished reports whether position, the last one polled, is at the end of content.
+func (opts *PlaybackOptions) isFinished(position, duration float64) bool {
+	tolerance := opts.PollInterval.Seconds() + endOfContentTolerance
+	return duration > 0 && position >= duration-tolerance
+}
+
+// waitForReadyToPlay returns the playback information when content becomes ready to play.
+func (c *Client) waitForReadyToPlay(ctx context.Context, opts *PlaybackOptions) (*PlaybackInfo, error) {
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(opts.ReadyTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, ErrNotReadyTimeout
+		case <-ticker.C:
+			info, err := c.GetPlaybackInfoContext(ctx)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if info.IsReadyToPlay {
+				return info, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package airplay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DHowett/go-plist"
+	"github.com/gongo/text-parameters"
+)
+
+// endedPlaybackInfo is still ready to play at the end of content.
+var endedPlaybackInfo = `
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>duration</key>
+	<real>36.00000</real>
+	<key>position</key>
+	<real>35.80000</real>
+	<key>readyToPlay</key>
+	<true/>
+</dict>
+</plist>`
+
+func TestPlaybackOptionsDefaults(t *testing.T) {
+	client, _ := NewClient(&ClientParam{Addr: "192.0.2.1"})
+	opts := client.playbackOptions(nil)
+
+	if opts.PollInterval != time.Second || opts.ReadyTimeout != 10*time.Second || opts.EndDetection != EndWhenNotReady {
+		t.Fatalf("Incorrect default options (actual %+v)", opts)
+	}
+
+	client, _ = NewClient(&ClientParam{Addr: "192.0.2.1", PollInterval: time.Millisecond, ReadyTimeout: time.Minute})
+	opts = client.playbackOptions(&PlaybackOptions{PollInterval: time.Hour})
+
+	if opts.PollInterval != time.Hour || opts.ReadyTimeout != time.Minute {
+		t.Fatalf("Options should be filled with the client defaults (actual %+v)", opts)
+	}
+}
+
+func TestPlayWithOptions(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
+		{"POST", "/play"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+	}
+	responseXMLs := []string{
+		stopPlaybackInfo,
+		playingPlaybackInfo,
+		endedPlaybackInfo,
+	}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/play" {
+			u := &playbackInfoParam{}
+			parameters.NewDecorder(req.Body).Decode(u)
+
+			if u.Seconds != 30.0 || u.Position != 0.0 {
+				t.Fatalf("Incorrect request position (actual %f seconds, %f)", u.Seconds, u.Position)
+			}
+		}
+
+		if req.URL.Path == "/playback-info" {
+			xml := responseXMLs[0]
+			responseXMLs = responseXMLs[1:]
+			w.Write([]byte(xml))
+		}
+	})
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, _ := NewClient(&ClientParam{Addr: addr, Port: port})
+	opts := &PlaybackOptions{
+		PollInterval:  testPollInterval,
+		StartPosition: 0.5,
+		StartTime:     30 * time.Second,
+		EndDetection:  EndAtDuration,
+	}
+
+	select {
+	case err := <-client.PlayWithOptions(context.Background(), "http://movie.example.com/go.mp4", opts):
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PollInterval of options is not applied")
+	}
+}
+
+// playbackResultServer responds to /playback-info with infos in order, and repeats the last one.
+func playbackResultServer(t *testing.T, infos ...map[string]interface{}) *httptest.Server {
+	var mu sync.Mutex
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/playback-info" {
+			return
+		}
+
+		mu.Lock()
+		info := infos[0]
+		if len(infos) > 1 {
+			infos = infos[1:]
+		}
+		mu.Unlock()
+
+		if info == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := plist.Marshal(info, plist.XMLFormat)
+		w.Write(body)
+	}))
+}
+
+func TestPlayWithResult(t *testing.T) {
+	playing := map[string]interface{}{"readyToPlay": true, "duration": 36.0, "position": 18.0, "uuid": "AAAAA"}
+	ended := map[string]interface{}{"readyToPlay": true, "duration": 36.0, "position": 35.8, "uuid": "AAAAA"}
+	stopped := map[string]interface{}{"readyToPlay": false}
+	other := map[string]interface{}{"readyToPlay": true, "duration": 10.0, "position": 1.0, "uuid": "BBBBB"}
+	stoppedWithUUID := map[string]interface{}{"readyToPlay": false, "uuid": "ZZZZZ"}
+
+	tests := []struct {
+		infos    []map[string]interface{}
+		reason   PlaybackEndReason
+		position float64
+	}{
+		{[]map[string]interface{}{playing, ended, stopped}, PlaybackFinished, 35.8},
+		{[]map[string]interface{}{playing, playing, stopped}, PlaybackStoppedByReceiver, 18.0},
+		{[]map[string]interface{}{playing, stopped}, PlaybackStoppedByReceiver, 18.0},
+		{[]map[string]interface{}{playing, playing, other}, PlaybackPreempted, 18.0},
+		{[]map[string]interface{}{playing, ended, stoppedWithUUID}, PlaybackFinished, 35.8},
+		{[]map[string]interface{}{playing, playing, nil}, PlaybackFailed, 18.0},
+	}
+
+	for _, test := range tests {
+		ts := playbackResultServer(t, test.infos...)
+		client := getTestClient(t, ts)
+
+		result := <-client.PlayWithResult(context.Background(), "http://movie.example.com/go.mp4", nil)
+		if result.Reason != test.reason || result.Position != test.position {
+			t.Errorf("Incorrect result (expected %s at %f, actual %s at %f)", test.reason, test.position, result.Reason, result.Position)
+		}
+
+		if (result.Err != nil) != (test.reason == PlaybackFailed) {
+			t.Errorf("Err should be set only when failed (actual %v)", result.Err)
+		}
+		ts.Close()
+	}
+}
+
+func TestPlayWithResultStoppedBySender(t *testing.T) {
+	ts := playbackResultServer(t, map[string]interface{}{"readyToPlay": true, "duration": 36.0, "position": 18.0})
+	defer ts.Close()
+
+	client := getTestClient(t, ts)
+	ch := client.PlayWithResult(context.Background(), "http://movie.example.com/go.mp4", nil)
+
+	time.AfterFunc(10*time.Millisecond, func() { client.Stop() })
+
+	select {
+	case result := <-ch:
+		if result.Reason != PlaybackStoppedBySender || result.Position != 18.0 {
+			t.Fatalf("Incorrect result (actual %s at %f)", result.Reason, result.Position)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop() is not detected")
+	}
+}
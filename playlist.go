@@ -0,0 +1,129 @@
+package airplay
+
+import "context"
+
+const (
+	actionTypePlaylistInsert = "playlistInsert"
+	actionTypePlaylistRemove = "playlistRemove"
+)
+
+// A PlaylistItem is an item of the playlist on the device.
+type PlaylistItem struct {
+	// UUID identifies the item on the device.
+	UUID string
+
+	// URL is the content URL.
+	URL string
+
+	// Position is the start position between 0 (0%) to 1 (100%).
+	Position float64
+}
+
+// playlistAction is the body of POST /action to edit the playlist.
+type playlistAction struct {
+	Type string       `plist:"type"`
+	Item *playRequest `plist:"item"`
+}
+
+// SupportsPlaylist reports whether the device may have its own playlist.
+//
+// It is true also when the features of the device are unknown.
+func (c *Client) SupportsPlaylist() bool {
+	features := c.Features()
+	return features == 0 || features.Has(FeatureVideoPlayQueue)
+}
+
+// PlaylistInsert adds url to the end of the playlist on the device.
+//
+// Items in the playlist are played after the current content without gaps,
+// and can be skipped by the remote of the device.
+func (c *Client) PlaylistInsert(ctx context.Context, url string, position float64) (PlaylistItem, error) {
+	item := PlaylistItem{
+		UUID:     newUUID(),
+		URL:      url,
+		Position: position,
+	}
+
+	err := c.sendPlaylistAction(ctx, actionTypePlaylistInsert, &playRequest{
+		ContentLocation: item.URL,
+		StartPosition:   item.Position,
+		UUID:            item.UUID,
+		ClientProcName:  clientProcName,
+	})
+	if err != nil {
+		return PlaylistItem{}, err
+	}
+
+	c.mu.Lock()
+	c.playlist = append(c.playlist, item)
+	c.mu.Unlock()
+
+	return item, nil
+}
+
+// PlaylistRemove removes item from the playlist on the device.
+func (c *Client) PlaylistRemove(ctx context.Context, item PlaylistItem) error {
+	err := c.sendPlaylistAction(ctx, actionTypePlaylistRemove, &playRequest{
+		ContentLocation: item.URL,
+		UUID:            item.UUID,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, inserted := range c.playlist {
+		if inserted.UUID == item.UUID {
+			c.playlist = append(c.playlist[:i], c.playlist[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Playlist returns the items inserted by PlaylistInsert since the last Play,
+// which are neither removed nor already played.
+//
+// The device does not report its playlist, so it is tracked by the client:
+// items added by other senders are not included, and played items are
+// pruned only when GetPlaybackInfo() finds a later item playing.
+func (c *Client) Playlist() []PlaylistItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]PlaylistItem{}, c.playlist...)
+}
+
+// prunePlaylist removes the items played before the item info is playing.
+func (c *Client) prunePlaylist(info *PlaybackInfo) {
+	if !info.IsReadyToPlay || info.UUID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, item := range c.playlist {
+		if item.UUID == info.UUID {
+			c.playlist = append([]PlaylistItem{}, c.playlist[i:]...)
+			return
+		}
+	}
+}
+
+func (c *Client) sendPlaylistAction(ctx context.Context, actionType string, item *playRequest) error {
+	if !c.SupportsPlaylist() {
+		return ErrPlaylistNotSupported
+	}
+
+	err := c.sendPlist(ctx, "action", &playlistAction{
+		Type: actionType,
+		Item: item,
+	})
+	if isNotSupported(err) {
+		return ErrPlaylistNotSupported
+	}
+	return err
+}
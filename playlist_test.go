@@ -0,0 +1,121 @@
+package airplay
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/DHowett/go-plist"
+)
+
+func TestPlaylist(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"POST", "/action"},
+		{"POST", "/action"},
+		{"POST", "/action"},
+	}
+	actions := []playlistAction{}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/x-apple-binary-plist" {
+			t.Fatalf("Incorrect content type (actual %s)", req.Header.Get("Content-Type"))
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		action := playlistAction{}
+		if _, err := plist.Unmarshal(body, &action); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, action)
+	})
+
+	client := getTestClient(t, ts)
+	ctx := context.Background()
+
+	first, err := client.PlaylistInsert(ctx, "http://movie.example.com/1.mp4", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.PlaylistInsert(ctx, "http://movie.example.com/2.mp4", 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actions[1].Type != "playlistInsert" || actions[1].Item.ContentLocation != second.URL ||
+		actions[1].Item.StartPosition != 0.5 || actions[1].Item.UUID != second.UUID {
+		t.Fatalf("Incorrect insert action (actual %+v)", actions[1].Item)
+	}
+
+	if err := client.PlaylistRemove(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	if actions[2].Type != "playlistRemove" || actions[2].Item.UUID != first.UUID {
+		t.Fatalf("Incorrect remove action (actual %+v)", actions[2].Item)
+	}
+
+	if playlist := client.Playlist(); len(playlist) != 1 || playlist[0] != second {
+		t.Fatalf("Incorrect playlist (actual %+v)", playlist)
+	}
+}
+
+func TestPlaylistNotSupported(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"POST", "/action"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	})
+
+	client := getTestClient(t, ts)
+	if _, err := client.PlaylistInsert(context.Background(), "http://movie.example.com/go.mp4", 0); err != ErrPlaylistNotSupported {
+		t.Fatalf("It should occurs ErrPlaylistNotSupported (actual %v)", err)
+	}
+
+	// Known features without the play queue are detected without requests.
+	client.connection.device.Extra.Features = "0x7"
+	if client.SupportsPlaylist() {
+		t.Fatal("Playlist should not be supported")
+	}
+	if _, err := client.PlaylistInsert(context.Background(), "http://movie.example.com/go.mp4", 0); err != ErrPlaylistNotSupported {
+		t.Fatalf("It should occurs ErrPlaylistNotSupported (actual %v)", err)
+	}
+
+	if len(client.Playlist()) != 0 {
+		t.Fatalf("Playlist should be empty (actual %+v)", client.Playlist())
+	}
+}
+
+func TestPlaylistPrunesPlayedItems(t *testing.T) {
+	var playing string
+	ts := airTestServer(t, []testExpectRequest{
+		{"POST", "/action"},
+		{"POST", "/action"},
+		{"POST", "/action"},
+		{"GET", "/playback-info"},
+	}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/playback-info" {
+			body, _ := plist.Marshal(map[string]interface{}{"readyToPlay": true, "uuid": playing}, plist.XMLFormat)
+			w.Write(body)
+		}
+	})
+
+	client := getTestClient(t, ts)
+	ctx := context.Background()
+
+	items := []PlaylistItem{}
+	for _, url := range []string{"http://movie.example.com/1.mp4", "http://movie.example.com/2.mp4", "http://movie.example.com/3.mp4"} {
+		item, err := client.PlaylistInsert(ctx, url, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+
+	playing = items[1].UUID
+	if _, err := client.GetPlaybackInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if playlist := client.Playlist(); len(playlist) != 2 || playlist[0] != items[1] || playlist[1] != items[2] {
+		t.Fatalf("Played items should be pruned (actual %+v)", playlist)
+	}
+}
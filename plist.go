@@ -0,0 +1,228 @@
+package airplay
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+// unmarshalPlist decodes a plist document (XML or binary) into v.
+//
+// Unlike plist.Unmarshal, it tolerates differences in value types between
+// devices. For example, readyToPlay is <true/> on AppleTV 2G/3G but
+// <integer>1</integer> on AppleTV 4G, and both are decoded into a bool field.
+// Values that cannot be converted are ignored and leave the field untouched.
+//
+// Struct fields are matched by the "plist" tag, or by the field name if the tag is absent.
+func unmarshalPlist(data []byte, v interface{}) error {
+	var raw interface{}
+	if _, err := plist.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	assignPlistValue(reflect.ValueOf(v).Elem(), raw)
+	return nil
+}
+
+// A plistValueDecoder is implemented by types that convert a decoded plist value by themselves.
+type plistValueDecoder interface {
+	decodePlistValue(raw interface{})
+}
+
+var (
+	timeType              = reflect.TypeOf(time.Time{})
+	plistValueDecoderType = reflect.TypeOf((*plistValueDecoder)(nil)).Elem()
+)
+
+// assignPlistValue stores the decoded plist value raw into dst with type conversion.
+func assignPlistValue(dst reflect.Value, raw interface{}) {
+	if raw == nil || !dst.CanSet() {
+		return
+	}
+
+	if dst.Kind() != reflect.Ptr && reflect.PtrTo(dst.Type()).Implements(plistValueDecoderType) {
+		dst.Addr().Interface().(plistValueDecoder).decodePlistValue(raw)
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if reflect.TypeOf(raw).AssignableTo(dst.Type()) {
+			dst.Set(reflect.ValueOf(raw))
+		}
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		assignPlistValue(elem.Elem(), raw)
+		dst.Set(elem)
+	case reflect.Bool:
+		if b, ok := plistBool(raw); ok {
+			dst.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch t := raw.(type) {
+		case int64:
+			dst.SetInt(t)
+		case uint64:
+			dst.SetInt(int64(t))
+		default:
+			if f, ok := plistFloat(raw); ok {
+				dst.SetInt(int64(f))
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := raw.(uint64); ok {
+			dst.SetUint(n)
+		} else if f, ok := plistFloat(raw); ok && f >= 0 {
+			dst.SetUint(uint64(f))
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := plistFloat(raw); ok {
+			dst.SetFloat(f)
+		}
+	case reflect.String:
+		if s, ok := plistString(raw); ok {
+			dst.SetString(s)
+		}
+	case reflect.Slice:
+		assignPlistSlice(dst, raw)
+	case reflect.Map:
+		assignPlistMap(dst, raw)
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			if t, ok := raw.(time.Time); ok {
+				dst.Set(reflect.ValueOf(t))
+			}
+			return
+		}
+		assignPlistStruct(dst, raw)
+	}
+}
+
+func assignPlistSlice(dst reflect.Value, raw interface{}) {
+	if dst.Type().Elem().Kind() == reflect.Uint8 {
+		switch t := raw.(type) {
+		case []byte:
+			dst.SetBytes(t)
+		case string:
+			dst.SetBytes([]byte(t))
+		}
+		return
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok {
+		// A single value is regarded as an array of one element.
+		values = []interface{}{raw}
+	}
+
+	slice := reflect.MakeSlice(dst.Type(), len(values), len(values))
+	for i, value := range values {
+		assignPlistValue(slice.Index(i), value)
+	}
+	dst.Set(slice)
+}
+
+func assignPlistMap(dst reflect.Value, raw interface{}) {
+	dict, ok := raw.(map[string]interface{})
+	if !ok || dst.Type().Key().Kind() != reflect.String {
+		return
+	}
+
+	m := reflect.MakeMap(dst.Type())
+	for key, value := range dict {
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		assignPlistValue(elem, value)
+		m.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+	}
+	dst.Set(m)
+}
+
+func assignPlistStruct(dst reflect.Value, raw interface{}) {
+	dict, ok := raw.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		key := field.Name
+		if tag := field.Tag.Get("plist"); tag != "" {
+			key = strings.Split(tag, ",")[0]
+		}
+		if key == "-" {
+			continue
+		}
+
+		if value, ok := dict[key]; ok {
+			assignPlistValue(dst.Field(i), value)
+		}
+	}
+}
+
+func plistBool(raw interface{}) (bool, bool) {
+	switch t := raw.(type) {
+	case bool:
+		return t, true
+	case string:
+		switch strings.ToLower(t) {
+		case "true", "yes", "1":
+			return true, true
+		case "false", "no", "0":
+			return false, true
+		}
+		return false, false
+	}
+
+	if f, ok := plistFloat(raw); ok {
+		return f != 0, true
+	}
+	return false, false
+}
+
+func plistFloat(raw interface{}) (float64, bool) {
+	switch t := raw.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func plistString(raw interface{}) (string, bool) {
+	switch t := raw.(type) {
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	case uint64:
+		return strconv.FormatUint(t, 10), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	return "", false
+}
@@ -0,0 +1,231 @@
+package airplay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+// Names of playback properties.
+const (
+	PropertyPlaybackAccessLog  = "playbackAccessLog"
+	PropertyPlaybackErrorLog   = "playbackErrorLog"
+	PropertyReverseEndTime     = "reverseEndTime"
+	PropertyForwardEndTime     = "forwardEndTime"
+	PropertyActionAtItemEnd    = "actionAtItemEnd"
+	PropertySelectedMediaArray = "selectedMediaArray"
+)
+
+// ActionAtItemEnd represents the action performed by the device when playback reaches the end.
+type ActionAtItemEnd int
+
+const (
+	ActionAtItemEndAdvance ActionAtItemEnd = 0
+	ActionAtItemEndPause   ActionAtItemEnd = 1
+	ActionAtItemEndNone    ActionAtItemEnd = 2
+)
+
+// An AccessLogEvent is an entry of playbackAccessLog.
+type AccessLogEvent struct {
+	URI                        string    `plist:"uri"`
+	ServerAddress              string    `plist:"s-ip"`
+	PlaybackSessionID          string    `plist:"cs-guid"`
+	PlaybackStartDate          time.Time `plist:"c-start-time"`
+	DurationWatched            float64   `plist:"c-duration-watched"`
+	IndicatedBitrate           float64   `plist:"sc-indicated-bitrate"`
+	ObservedBitrate            float64   `plist:"c-observed-bitrate"`
+	NumberOfStalls             int       `plist:"c-stalls"`
+	NumberOfDroppedVideoFrames int       `plist:"c-frame-drops"`
+	NumberOfBytesTransferred   int64     `plist:"bytes"`
+}
+
+// An ErrorLogEvent is an entry of playbackErrorLog.
+type ErrorLogEvent struct {
+	Date              time.Time `plist:"date"`
+	URI               string    `plist:"uri"`
+	ServerAddress     string    `plist:"s-ip"`
+	PlaybackSessionID string    `plist:"cs-guid"`
+	StatusCode        int       `plist:"status"`
+	Domain            string    `plist:"domain"`
+	Comment           string    `plist:"comment"`
+}
+
+// propertyResponse is the body of GET /getProperty.
+type propertyResponse struct {
+	ErrorCode int         `plist:"errorCode"`
+	Value     interface{} `plist:"value"`
+}
+
+// propertyRequest is the body of PUT /setProperty.
+type propertyRequest struct {
+	Value interface{} `plist:"value"`
+}
+
+// Flags of cmTime.
+const (
+	cmTimeFlagValid      = 1 << 0
+	cmTimeFlagIndefinite = 1 << 4
+)
+
+// cmTime is a time value understood by the device (CMTime).
+type cmTime struct {
+	Value     int64  `plist:"value"`
+	Timescale int32  `plist:"timescale"`
+	Flags     uint32 `plist:"flags"`
+	Epoch     int64  `plist:"epoch"`
+}
+
+func newCMTime(d time.Duration) cmTime {
+	return cmTime{
+		Value:     int64(d / time.Millisecond),
+		Timescale: 1000,
+		Flags:     cmTimeFlagValid,
+	}
+}
+
+// duration returns t as time.Duration. Invalid or indefinite time is 0.
+func (t cmTime) duration() time.Duration {
+	if t.Flags&cmTimeFlagValid == 0 || t.Flags&cmTimeFlagIndefinite != 0 || t.Timescale <= 0 {
+		return 0
+	}
+	return time.Duration(t.Value) * time.Second / time.Duration(t.Timescale)
+}
+
+// GetProperty retrieves the playback property name by GET /getProperty and stores the value in v.
+//
+// v is a pointer to any type the value can be converted to, e.g. *interface{} for the raw plist value.
+func (c *Client) GetProperty(ctx context.Context, name string, v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("airplay: [ERR] GetProperty(%s) requires a non-nil pointer (actual %T)", name, v)
+	}
+
+	response, err := c.connection.get(ctx, "getProperty?"+url.QueryEscape(name))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	property := &propertyResponse{}
+	if err := decodeBody(response.Header.Get("Content-Type"), body, property); err != nil {
+		return err
+	}
+
+	if property.ErrorCode != 0 {
+		return &PropertyError{Name: name, ErrorCode: property.ErrorCode}
+	}
+
+	assignPlistValue(reflect.ValueOf(v).Elem(), property.Value)
+	return nil
+}
+
+// SetProperty sets value to the playback property name by PUT /setProperty.
+//
+// value is any plist-encodable value.
+func (c *Client) SetProperty(ctx context.Context, name string, value interface{}) error {
+	body, err := plist.Marshal(&propertyRequest{Value: value}, plist.BinaryFormat)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{
+		"Content-Type": {contentTypeBinaryPlist},
+	}
+	response, err := c.connection.putWithHeader(ctx, "setProperty?"+url.QueryEscape(name), bytes.NewReader(body), header)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err = ioutil.ReadAll(response.Body)
+	if err != nil || len(body) == 0 {
+		return err
+	}
+
+	property := &propertyResponse{}
+	if err := decodeBody(response.Header.Get("Content-Type"), body, property); err != nil {
+		return nil // Some devices respond with an empty or non-plist body.
+	}
+
+	if property.ErrorCode != 0 {
+		return &PropertyError{Name: name, ErrorCode: property.ErrorCode}
+	}
+	return nil
+}
+
+// PlaybackAccessLog retrieves the access log of playing content.
+func (c *Client) PlaybackAccessLog(ctx context.Context) ([]AccessLogEvent, error) {
+	events := []AccessLogEvent{}
+	if err := c.GetProperty(ctx, PropertyPlaybackAccessLog, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// PlaybackErrorLog retrieves the error log of playing content.
+//
+// It is useful to know why playback failed.
+func (c *Client) PlaybackErrorLog(ctx context.Context) ([]ErrorLogEvent, error) {
+	events := []ErrorLogEvent{}
+	if err := c.GetProperty(ctx, PropertyPlaybackErrorLog, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ForwardEndTime retrieves the time at which playback stops when playing forward.
+//
+// It returns 0 if the time is not set.
+func (c *Client) ForwardEndTime(ctx context.Context) (time.Duration, error) {
+	return c.endTime(ctx, PropertyForwardEndTime)
+}
+
+// ReverseEndTime retrieves the time at which playback stops when playing in reverse.
+//
+// It returns 0 if the time is not set.
+func (c *Client) ReverseEndTime(ctx context.Context) (time.Duration, error) {
+	return c.endTime(ctx, PropertyReverseEndTime)
+}
+
+func (c *Client) endTime(ctx context.Context, name string) (time.Duration, error) {
+	var t cmTime
+	if err := c.GetProperty(ctx, name, &t); err != nil {
+		return 0, err
+	}
+	return t.duration(), nil
+}
+
+// ActionAtItemEnd retrieves the action performed when playback reaches the end.
+func (c *Client) ActionAtItemEnd(ctx context.Context) (ActionAtItemEnd, error) {
+	var action ActionAtItemEnd
+	if err := c.GetProperty(ctx, PropertyActionAtItemEnd, &action); err != nil {
+		return 0, err
+	}
+	return action, nil
+}
+
+// SetForwardEndTime sets the time at which playback stops when playing forward.
+func (c *Client) SetForwardEndTime(ctx context.Context, d time.Duration) error {
+	return c.SetProperty(ctx, PropertyForwardEndTime, newCMTime(d))
+}
+
+// SetReverseEndTime sets the time at which playback stops when playing in reverse.
+func (c *Client) SetReverseEndTime(ctx context.Context, d time.Duration) error {
+	return c.SetProperty(ctx, PropertyReverseEndTime, newCMTime(d))
+}
+
+// SetActionAtItemEnd sets the action performed when playback reaches the end.
+func (c *Client) SetActionAtItemEnd(ctx context.Context, action ActionAtItemEnd) error {
+	return c.SetProperty(ctx, PropertyActionAtItemEnd, int(action))
+}
@@ -0,0 +1,157 @@
+package airplay
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+func TestGetProperty(t *testing.T) {
+	body, err := plist.Marshal(map[string]interface{}{
+		"errorCode": uint64(0),
+		"value": []interface{}{
+			map[string]interface{}{
+				"uri":    "http://movie.example.com/go.m3u8",
+				"status": int64(-12938),
+				"domain": "CoreMediaErrorDomain",
+			},
+		},
+	}, plist.BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/getProperty"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.RawQuery != "playbackErrorLog" {
+			t.Fatalf("Incorrect property name (actual %s)", req.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/x-apple-binary-plist")
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+	events, err := client.PlaybackErrorLog(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Incorrect error log (actual %v)", events)
+	}
+
+	if events[0].URI != "http://movie.example.com/go.m3u8" || events[0].StatusCode != -12938 || events[0].Domain != "CoreMediaErrorDomain" {
+		t.Fatalf("Incorrect error log event (actual %+v)", events[0])
+	}
+}
+
+func TestGetPropertyWithErrorCode(t *testing.T) {
+	body, _ := plist.Marshal(map[string]interface{}{"errorCode": uint64(404)}, plist.XMLFormat)
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/getProperty"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+
+	var value interface{}
+	err := client.GetProperty(context.Background(), "unknownProperty", &value)
+	if perr, ok := err.(*PropertyError); !ok || perr.ErrorCode != 404 || perr.Name != "unknownProperty" {
+		t.Fatalf("It should occurs *PropertyError (actual %v)", err)
+	}
+}
+
+func TestGetPropertyWithNonPointer(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{}, nil)
+	client := getTestClient(t, ts)
+
+	var value int
+	for _, v := range []interface{}{nil, value, (*int)(nil)} {
+		if err := client.GetProperty(context.Background(), PropertyPlaybackErrorLog, v); err == nil {
+			t.Fatalf("It should occurs error for %#v", v)
+		}
+	}
+}
+
+func TestSetForwardEndTime(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"PUT", "/setProperty"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.RawQuery != "forwardEndTime" {
+			t.Fatalf("Incorrect property name (actual %s)", req.URL.RawQuery)
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		var params struct {
+			Value map[string]interface{} `plist:"value"`
+		}
+		if _, err := plist.Unmarshal(body, &params); err != nil {
+			t.Fatal(err)
+		}
+
+		if params.Value["value"] != uint64(90500) || params.Value["timescale"] != uint64(1000) {
+			t.Fatalf("Incorrect request body (actual %v)", params.Value)
+		}
+	})
+
+	client := getTestClient(t, ts)
+	if err := client.SetForwardEndTime(context.Background(), 90500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForwardEndTime(t *testing.T) {
+	body, _ := plist.Marshal(map[string]interface{}{
+		"errorCode": uint64(0),
+		"value": map[string]interface{}{
+			"value":     int64(90500),
+			"timescale": int64(1000),
+			"flags":     uint64(1),
+			"epoch":     int64(0),
+		},
+	}, plist.BinaryFormat)
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/getProperty"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.RawQuery != "forwardEndTime" {
+			t.Fatalf("Incorrect property name (actual %s)", req.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/x-apple-binary-plist")
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+	d, err := client.ForwardEndTime(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := 90500 * time.Millisecond; d != expect {
+		t.Fatalf("Incorrect forward end time (expected %v, actual %v)", expect, d)
+	}
+}
+
+func TestActionAtItemEnd(t *testing.T) {
+	body, _ := plist.Marshal(map[string]interface{}{"errorCode": uint64(0), "value": uint64(1)}, plist.BinaryFormat)
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/getProperty"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		if req.URL.RawQuery != "actionAtItemEnd" {
+			t.Fatalf("Incorrect property name (actual %s)", req.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/x-apple-binary-plist")
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+	action, err := client.ActionAtItemEnd(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if action != ActionAtItemEndPause {
+		t.Fatalf("Incorrect action at item end (expected %v, actual %v)", ActionAtItemEndPause, action)
+	}
+}
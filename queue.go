@@ -0,0 +1,443 @@
+package airplay
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RepeatMode represents how a Queue repeats items.
+type RepeatMode int
+
+const (
+	// RepeatOff plays each item once.
+	RepeatOff RepeatMode = iota
+
+	// RepeatOne plays the current item repeatedly.
+	RepeatOne
+
+	// RepeatAll plays all items repeatedly.
+	RepeatAll
+)
+
+// A QueueItem is an item of Queue.
+type QueueItem struct {
+	// URL is the content URL.
+	URL string
+
+	// Position is the start position between 0 (0%) to 1 (100%), same as PlayAt().
+	Position float64
+}
+
+// A QueueEvent is a change of the current item of Queue.
+type QueueEvent struct {
+	// Index is the index of the current item, or -1 when the queue has finished.
+	Index int
+
+	// Item is the current item.
+	Item QueueItem
+
+	// Finished, if true, the queue has finished.
+	Finished bool
+
+	// Reason is why the queue has finished. It is PlaybackFinished
+	// when the end of the queue is reached, otherwise why playback was ended.
+	Reason PlaybackEndReason
+
+	// Err is the failure that finished the queue.
+	Err error
+}
+
+type queueCommand int
+
+const (
+	queueNext queueCommand = iota
+	queuePrevious
+)
+
+type queueEntry struct {
+	id   int
+	item QueueItem
+}
+
+// A Queue plays items one after another on the device of client.
+//
+// It moves to the next item when playback of the current item is finished,
+// which is detected in the same way as Play(), i.e. the device is no longer
+// ready to play. If playback is stopped by Stop() or other senders instead,
+// the queue finishes.
+type Queue struct {
+	client   *Client
+	events   chan QueueEvent
+	commands chan queueCommand
+	random   *rand.Rand
+
+	mu      sync.Mutex
+	entries []queueEntry
+	nextID  int
+	current int // id of the current entry, or -1
+	cursor  int // index of the current entry, kept even if it is removed
+	history []int
+	played  map[int]bool
+	repeat  RepeatMode
+	shuffle bool
+	running bool
+}
+
+// NewQueue returns an empty queue bound to client.
+func NewQueue(client *Client) *Queue {
+	return &Queue{
+		client:   client,
+		events:   make(chan QueueEvent, 16),
+		commands: make(chan queueCommand, 1),
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		current:  -1,
+		cursor:   -1,
+		played:   map[int]bool{},
+	}
+}
+
+// Events returns the channel that receives changes of the current item.
+//
+// Events are dropped if the channel is not received in time.
+func (q *Queue) Events() <-chan QueueEvent {
+	return q.events
+}
+
+// Items returns the items of the queue.
+func (q *Queue) Items() []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]QueueItem, len(q.entries))
+	for i, entry := range q.entries {
+		items[i] = entry.item
+	}
+	return items
+}
+
+// Len returns the number of items.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.entries)
+}
+
+// Append adds items to the end of the queue.
+func (q *Queue) Append(items ...QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.entries = append(q.entries, q.newEntries(items)...)
+}
+
+// Insert adds items before the item at index.
+func (q *Queue) Insert(index int, items ...QueueItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if index < 0 || index > len(q.entries) {
+		return ErrQueueIndexOutOfRange
+	}
+
+	entries := append([]queueEntry{}, q.entries[:index]...)
+	entries = append(entries, q.newEntries(items)...)
+	q.entries = append(entries, q.entries[index:]...)
+
+	if q.cursor >= index {
+		q.cursor += len(items)
+	}
+	return nil
+}
+
+// Remove removes the item at index.
+//
+// If it is the current item, playback continues and the queue moves to the item following it.
+func (q *Queue) Remove(index int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if index < 0 || index >= len(q.entries) {
+		return ErrQueueIndexOutOfRange
+	}
+
+	id := q.entries[index].id
+	q.entries = append(q.entries[:index], q.entries[index+1:]...)
+	delete(q.played, id)
+
+	if id == q.current {
+		q.current = -1
+		q.cursor = index - 1
+	} else if q.cursor > index {
+		q.cursor--
+	}
+	return nil
+}
+
+// Move moves the item at from to the position of to.
+func (q *Queue) Move(from, to int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if from < 0 || from >= len(q.entries) || to < 0 || to >= len(q.entries) {
+		return ErrQueueIndexOutOfRange
+	}
+
+	entry := q.entries[from]
+	q.entries = append(q.entries[:from], q.entries[from+1:]...)
+	q.entries = append(q.entries[:to], append([]queueEntry{entry}, q.entries[to:]...)...)
+
+	if index := q.indexOf(q.current); index >= 0 {
+		q.cursor = index
+		return nil
+	}
+
+	// The current entry has been removed, so the cursor is shifted
+	// the same way as Remove() and Insert() to keep the next entry.
+	if q.cursor >= from {
+		q.cursor--
+	}
+	if q.cursor >= to {
+		q.cursor++
+	}
+	return nil
+}
+
+// SetRepeat sets the repeat mode.
+func (q *Queue) SetRepeat(mode RepeatMode) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.repeat = mode
+}
+
+// SetShuffle enables or disables shuffle.
+func (q *Queue) SetShuffle(shuffle bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.shuffle = shuffle
+	q.played = map[int]bool{}
+	if q.current >= 0 {
+		q.played[q.current] = true
+	}
+}
+
+// Next skips to the next item while the queue is running.
+func (q *Queue) Next() error {
+	return q.command(queueNext)
+}
+
+// Previous goes back to the previous item while the queue is running.
+func (q *Queue) Previous() error {
+	return q.command(queuePrevious)
+}
+
+func (q *Queue) command(cmd queueCommand) error {
+	// q.mu is held while sending, so that Run() never leaves a command behind.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.running {
+		return ErrQueueNotRunning
+	}
+
+	select {
+	case q.commands <- cmd:
+	default: // A command is already pending.
+	}
+	return nil
+}
+
+// Run plays items until the end of the queue, or until ctx is done.
+//
+// It returns nil when the queue has finished or playback has been stopped,
+// otherwise the error that stopped it.
+func (q *Queue) Run(ctx context.Context) error {
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return ErrQueueRunning
+	}
+	q.running = true
+	entry, ok := q.advance(queueNext, false)
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.running = false
+		q.mu.Unlock()
+
+		// A command sent while finishing must not be applied to the next Run().
+		select {
+		case <-q.commands:
+		default:
+		}
+	}()
+
+	for ok {
+		q.emit(QueueEvent{Index: q.indexOfLocked(entry.id), Item: entry.item})
+
+		playCtx, cancel := context.WithCancel(ctx)
+		opts := &PlaybackOptions{StartPosition: entry.item.Position}
+		ch := q.client.PlayWithResult(playCtx, entry.item.URL, opts)
+
+		select {
+		case result := <-ch:
+			cancel()
+			switch result.Reason {
+			case PlaybackFailed, PlaybackStoppedBySender, PlaybackPreempted:
+				q.emit(QueueEvent{Index: -1, Finished: true, Reason: result.Reason, Err: result.Err})
+				return result.Err
+			}
+
+			q.mu.Lock()
+			entry, ok = q.advance(queueNext, true)
+			q.mu.Unlock()
+		case cmd := <-q.commands:
+			cancel()
+			<-ch
+
+			q.mu.Lock()
+			entry, ok = q.advance(cmd, false)
+			q.mu.Unlock()
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	q.emit(QueueEvent{Index: -1, Finished: true, Reason: PlaybackFinished})
+	return nil
+}
+
+// advance moves to the next or previous entry and returns it.
+// auto is true when the current item has been played to the end.
+//
+// q.mu must be held.
+func (q *Queue) advance(cmd queueCommand, auto bool) (queueEntry, bool) {
+	if len(q.entries) == 0 {
+		return queueEntry{}, false
+	}
+
+	if auto && q.repeat == RepeatOne {
+		if index := q.indexOf(q.current); index >= 0 {
+			return q.entries[index], true
+		}
+	}
+
+	var index int
+	if cmd == queuePrevious {
+		index = q.previousIndex()
+	} else {
+		index = q.nextIndex()
+	}
+
+	if index < 0 {
+		return queueEntry{}, false
+	}
+
+	if cmd == queueNext && q.current >= 0 {
+		q.history = append(q.history, q.current)
+	}
+
+	entry := q.entries[index]
+	q.current = entry.id
+	q.cursor = index
+	q.played[entry.id] = true
+	return entry, true
+}
+
+func (q *Queue) nextIndex() int {
+	if q.shuffle {
+		candidates := q.unplayed()
+		if len(candidates) == 0 && q.repeat == RepeatAll {
+			q.played = map[int]bool{q.current: true}
+			candidates = q.unplayed()
+			if len(candidates) == 0 {
+				return q.indexOf(q.current)
+			}
+		}
+		if len(candidates) == 0 {
+			return -1
+		}
+		return candidates[q.random.Intn(len(candidates))]
+	}
+
+	next := q.cursor + 1
+	if next >= len(q.entries) {
+		if q.repeat != RepeatAll {
+			return -1
+		}
+		next = 0
+	}
+	return next
+}
+
+func (q *Queue) previousIndex() int {
+	for len(q.history) > 0 {
+		id := q.history[len(q.history)-1]
+		q.history = q.history[:len(q.history)-1]
+		if index := q.indexOf(id); index >= 0 {
+			return index
+		}
+	}
+
+	if q.shuffle {
+		return q.indexOf(q.current)
+	}
+
+	previous := q.cursor - 1
+	if previous < 0 {
+		if q.repeat == RepeatAll {
+			return len(q.entries) - 1
+		}
+		return 0
+	}
+	return previous
+}
+
+func (q *Queue) unplayed() []int {
+	indexes := []int{}
+	for i, entry := range q.entries {
+		if !q.played[entry.id] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func (q *Queue) newEntries(items []QueueItem) []queueEntry {
+	entries := make([]queueEntry, len(items))
+	for i, item := range items {
+		entries[i] = queueEntry{id: q.nextID, item: item}
+		q.nextID++
+	}
+	return entries
+}
+
+func (q *Queue) indexOf(id int) int {
+	for i, entry := range q.entries {
+		if entry.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queue) indexOfLocked(id int) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.indexOf(id)
+}
+
+func (q *Queue) emit(event QueueEvent) {
+	select {
+	case q.events <- event:
+	default:
+	}
+}
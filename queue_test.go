@@ -0,0 +1,264 @@
+package airplay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gongo/text-parameters"
+)
+
+// queueTestServer plays each item to the end just after /play.
+func queueTestServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	played := []string{}
+	playing := false
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.URL.Path {
+		case "/play":
+			u := &playbackInfoParam{}
+			parameters.NewDecorder(req.Body).Decode(u)
+			played = append(played, u.Location)
+			playing = true
+		case "/playback-info":
+			if playing {
+				w.Write([]byte(playingPlaybackInfo))
+				playing = false
+			} else {
+				w.Write([]byte(stopPlaybackInfo))
+			}
+		}
+	}))
+
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, played...)
+	}
+}
+
+func TestQueueRun(t *testing.T) {
+	ts, played := queueTestServer(t)
+	defer ts.Close()
+
+	queue := NewQueue(getTestClient(t, ts))
+	queue.Append(QueueItem{URL: "a"}, QueueItem{URL: "c"})
+	if err := queue.Insert(1, QueueItem{URL: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := queue.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := []string{"a", "b", "c"}; !reflect.DeepEqual(played(), expect) {
+		t.Fatalf("Incorrect play order (expected %v, actual %v)", expect, played())
+	}
+
+	for i, url := range []string{"a", "b", "c"} {
+		event := <-queue.Events()
+		if event.Index != i || event.Item.URL != url || event.Finished {
+			t.Fatalf("Incorrect event (actual %+v)", event)
+		}
+	}
+
+	if event := <-queue.Events(); !event.Finished || event.Index != -1 || event.Reason != PlaybackFinished || event.Err != nil {
+		t.Fatalf("Queue should be finished (actual %+v)", event)
+	}
+}
+
+func TestQueueStopBySender(t *testing.T) {
+	started := make(chan struct{})
+	var once sync.Once
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/playback-info" {
+			w.Write([]byte(playingPlaybackInfo))
+			once.Do(func() { close(started) })
+		}
+	}))
+	defer ts.Close()
+
+	client := getTestClient(t, ts)
+	queue := NewQueue(client)
+	queue.Append(QueueItem{URL: "a"}, QueueItem{URL: "b"})
+
+	done := make(chan error)
+	go func() { done <- queue.Run(context.Background()) }()
+
+	select {
+	case event := <-queue.Events():
+		if event.Item.URL != "a" {
+			t.Fatalf("Incorrect current item (expected a, actual %s)", event.Item.URL)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queue did not start")
+	}
+	<-started
+	client.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queue was not finished")
+	}
+
+	if event := <-queue.Events(); !event.Finished || event.Reason != PlaybackStoppedBySender {
+		t.Fatalf("Queue should be finished by sender (actual %+v)", event)
+	}
+}
+
+func TestQueueNext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/playback-info" {
+			w.Write([]byte(playingPlaybackInfo))
+		}
+	}))
+	defer ts.Close()
+
+	queue := NewQueue(getTestClient(t, ts))
+	queue.Append(QueueItem{URL: "a"}, QueueItem{URL: "b"})
+
+	if err := queue.Next(); err != ErrQueueNotRunning {
+		t.Fatalf("It should occurs not running error (actual %v)", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- queue.Run(context.Background()) }()
+
+	for _, url := range []string{"a", "b"} {
+		select {
+		case event := <-queue.Events():
+			if event.Item.URL != url {
+				t.Fatalf("Incorrect current item (expected %s, actual %s)", url, event.Item.URL)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Queue did not move to the next item")
+		}
+		queue.Next()
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queue was not finished")
+	}
+}
+
+func TestQueueAdvance(t *testing.T) {
+	queue := NewQueue(nil)
+	queue.Append(QueueItem{URL: "a"}, QueueItem{URL: "b"}, QueueItem{URL: "c"})
+
+	next := func(cmd queueCommand, auto bool) string {
+		entry, ok := queue.advance(cmd, auto)
+		if !ok {
+			return ""
+		}
+		return entry.item.URL
+	}
+
+	if url := next(queueNext, false); url != "a" {
+		t.Fatalf("First item should be a (actual %s)", url)
+	}
+
+	queue.SetRepeat(RepeatOne)
+	if url := next(queueNext, true); url != "a" {
+		t.Fatalf("RepeatOne should replay a (actual %s)", url)
+	}
+	if url := next(queueNext, false); url != "b" {
+		t.Fatalf("Next should skip to b even in RepeatOne (actual %s)", url)
+	}
+	if url := next(queuePrevious, false); url != "a" {
+		t.Fatalf("Previous should go back to a (actual %s)", url)
+	}
+
+	queue.SetRepeat(RepeatAll)
+	queue.Move(0, 2) // b, c, a
+	if url := next(queueNext, true); url != "b" {
+		t.Fatalf("RepeatAll should wrap around to b (actual %s)", url)
+	}
+
+	queue.Remove(0) // c, a
+	if url := next(queueNext, true); url != "c" {
+		t.Fatalf("Item following removed b should be c (actual %s)", url)
+	}
+
+	queue.SetRepeat(RepeatOff)
+	queue.SetShuffle(true)
+	seen := map[string]bool{"c": true}
+	for url := next(queueNext, true); url != ""; url = next(queueNext, true) {
+		if seen[url] {
+			t.Fatalf("Shuffle should not replay %s", url)
+		}
+		seen[url] = true
+	}
+	if len(seen) != 2 {
+		t.Fatalf("Shuffle should play all items (actual %v)", seen)
+	}
+}
+
+func TestQueueMoveAfterRemoveCurrent(t *testing.T) {
+	queue := NewQueue(nil)
+	queue.Append(QueueItem{URL: "a"}, QueueItem{URL: "b"}, QueueItem{URL: "c"}, QueueItem{URL: "d"})
+
+	queue.advance(queueNext, false)
+	queue.advance(queueNext, false) // b
+
+	queue.Remove(1)  // a, c, d
+	queue.Move(0, 2) // c, d, a
+
+	if entry, ok := queue.advance(queueNext, true); !ok || entry.item.URL != "c" {
+		t.Fatalf("Item following removed b should be c (actual %s)", entry.item.URL)
+	}
+}
+
+func TestQueueIndexOutOfRange(t *testing.T) {
+	queue := NewQueue(nil)
+	queue.Append(QueueItem{URL: "a"})
+
+	if err := queue.Insert(2, QueueItem{URL: "b"}); err != ErrQueueIndexOutOfRange {
+		t.Fatalf("Insert should fail (actual %v)", err)
+	}
+	if err := queue.Remove(1); err != ErrQueueIndexOutOfRange {
+		t.Fatalf("Remove should fail (actual %v)", err)
+	}
+	if err := queue.Move(0, 1); err != ErrQueueIndexOutOfRange {
+		t.Fatalf("Move should fail (actual %v)", err)
+	}
+}
+
+func TestQueueRunDiscardsPendingCommand(t *testing.T) {
+	queue := NewQueue(nil)
+
+	// As if Next() was called while Run() was finishing.
+	queue.running = true
+	if err := queue.Next(); err != nil {
+		t.Fatal(err)
+	}
+	queue.running = false
+
+	if err := queue.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.commands) != 0 {
+		t.Fatal("Pending command should be discarded when Run finishes")
+	}
+
+	queue.running = true
+	if err := queue.Run(context.Background()); err != ErrQueueRunning {
+		t.Fatalf("It should occurs ErrQueueRunning (actual %v)", err)
+	}
+}
@@ -0,0 +1,141 @@
+package airplay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Purposes of the reverse connection, sent as X-Apple-Purpose.
+const (
+	reversePurposeEvent     = "event"
+	reversePurposeSlideshow = "slideshow"
+)
+
+// A reverseHandler handles a request sent by the device over the reverse connection.
+//
+// If it returns nil, an empty 200 OK response is sent back.
+type reverseHandler func(req *http.Request) *http.Response
+
+// A reverseConn is a reverse HTTP (PTTH/1.0) connection.
+//
+// After the upgrade, the roles are swapped: the device sends requests
+// and the client responds to them.
+type reverseConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// openReverse opens a reverse HTTP connection which belongs to the same session as other requests.
+//
+// The connection is closed when ctx is done.
+func (c *connection) openReverse(ctx context.Context, purpose string) (*reverseConn, error) {
+	if c.dialer == nil {
+		return nil, newRequestError("POST", "reverse", ErrReverseUnavailable)
+	}
+
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.hostPort())
+	if err != nil {
+		return nil, c.requestError(ctx, "POST", "reverse", err)
+	}
+
+	req, err := http.NewRequest("POST", c.endpoint()+"reverse", nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "PTTH/1.0")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("X-Apple-Purpose", purpose)
+	c.session.stamp(req.Header)
+	c.authorize(req.Header, "POST", "reverse")
+
+	r := &reverseConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		closed: make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.close()
+		case <-r.closed:
+		}
+	}()
+
+	if err := req.Write(conn); err != nil {
+		r.close()
+		return nil, c.requestError(ctx, "POST", "reverse", err)
+	}
+
+	response, err := http.ReadResponse(r.reader, req)
+	if err != nil {
+		r.close()
+		return nil, c.requestError(ctx, "POST", "reverse", err)
+	}
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		err := newStatusError("reverse", response)
+		r.close()
+		return nil, newRequestError("POST", "reverse", err)
+	}
+
+	return r, nil
+}
+
+// serve reads requests from the device and responds to them until the connection is closed.
+func (r *reverseConn) serve(handler reverseHandler) error {
+	defer r.close()
+
+	for {
+		req, err := http.ReadRequest(r.reader)
+		if err != nil {
+			return err
+		}
+
+		response := handler(req)
+		io.Copy(ioutil.Discard, req.Body)
+		req.Body.Close()
+
+		if response == nil {
+			response = newReverseResponse(http.StatusOK, "", nil)
+		}
+
+		if err := response.Write(r.conn); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *reverseConn) close() {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		r.conn.Close()
+	})
+}
+
+func newReverseResponse(statusCode int, contentType string, body []byte) *http.Response {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		StatusCode:    statusCode,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
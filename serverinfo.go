@@ -0,0 +1,254 @@
+package airplay
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Features is a bitmask of the features supported by AirPlay device.
+type Features uint64
+
+const (
+	FeatureVideo                Features = 1 << 0
+	FeaturePhoto                Features = 1 << 1
+	FeatureVideoFairPlay        Features = 1 << 2
+	FeatureVideoVolumeControl   Features = 1 << 3
+	FeatureVideoHTTPLiveStreams Features = 1 << 4
+	FeatureSlideshow            Features = 1 << 5
+	FeatureScreen               Features = 1 << 7
+	FeatureScreenRotate         Features = 1 << 8
+	FeatureAudio                Features = 1 << 9
+	FeatureAudioRedundant       Features = 1 << 11
+	FeaturePhotoCaching         Features = 1 << 13
+	FeatureVideoPlayQueue       Features = 1 << 33
+	FeatureUnifiedMediaControl  Features = 1 << 38
+)
+
+// ParseFeatures parses features in the format of mDNS TXT record (e.g. "0x5A7FFFF7,0x1E").
+//
+// The first value is the lower 32 bits and the optional second value is the upper 32 bits.
+func ParseFeatures(s string) (Features, error) {
+	var features Features
+
+	for i, part := range strings.SplitN(s, ",", 2) {
+		part = strings.TrimSpace(part)
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(part), "0x"), 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("airplay: [ERR] Invalid features %q", s)
+		}
+		features |= Features(n << uint(32*i))
+	}
+
+	return features, nil
+}
+
+// Has reports whether all of feature are supported.
+func (f Features) Has(feature Features) bool {
+	return f&feature == feature
+}
+
+// String returns features in the format of mDNS TXT record.
+func (f Features) String() string {
+	if f>>32 == 0 {
+		return fmt.Sprintf("0x%X", uint64(f))
+	}
+	return fmt.Sprintf("0x%X,0x%X", uint64(f&0xFFFFFFFF), uint64(f>>32))
+}
+
+func (f *Features) decodePlistValue(raw interface{}) {
+	switch t := raw.(type) {
+	case uint64:
+		*f = Features(t)
+	case int64:
+		*f = Features(t)
+	case string:
+		if features, err := ParseFeatures(t); err == nil {
+			*f = features
+		}
+	}
+}
+
+// A ServerInfo is an information of AirPlay device retrieved from the device itself.
+type ServerInfo struct {
+	DeviceID        string   `plist:"deviceid"`
+	Features        Features `plist:"features"`
+	Model           string   `plist:"model"`
+	Name            string   `plist:"name"`
+	ProtocolVersion string   `plist:"protovers"`
+	SourceVersion   string   `plist:"srcvers"`
+	VV              int      `plist:"vv"`
+	MacAddress      string   `plist:"macAddress"`
+
+	// Width and Height represent the display size reported by AirPlay 1 devices.
+	Width  int `plist:"width"`
+	Height int `plist:"height"`
+
+	// Displays, AudioFormats and PublicKey are reported by AirPlay 2 devices.
+	Displays     []DisplayInfo `plist:"displays"`
+	AudioFormats []AudioFormat `plist:"audioFormats"`
+	PublicKey    []byte        `plist:"pk"`
+}
+
+// A DisplayInfo is a display attached to AirPlay device.
+type DisplayInfo struct {
+	UUID         string  `plist:"uuid"`
+	Width        int     `plist:"width"`
+	Height       int     `plist:"height"`
+	WidthPixels  int     `plist:"widthPixels"`
+	HeightPixels int     `plist:"heightPixels"`
+	RefreshRate  float64 `plist:"refreshRate"`
+	Rotation     bool    `plist:"rotation"`
+}
+
+// An AudioFormat is a set of audio formats supported by AirPlay device for a stream type.
+type AudioFormat struct {
+	Type          int    `plist:"type"`
+	InputFormats  uint64 `plist:"audioInputFormats"`
+	OutputFormats uint64 `plist:"audioOutputFormats"`
+}
+
+// serverInfoAliases is keys of /info that differ from /server-info.
+type serverInfoAliases struct {
+	DeviceID        string `plist:"deviceID"`
+	SourceVersion   string `plist:"sourceVersion"`
+	ProtocolVersion string `plist:"protocolVersion"`
+}
+
+// DisplaySize returns the size of the main display in pixels.
+//
+// If the device does not report it, both are 0.
+func (s *ServerInfo) DisplaySize() (width, height int) {
+	for _, display := range s.Displays {
+		if display.WidthPixels > 0 && display.HeightPixels > 0 {
+			return display.WidthPixels, display.HeightPixels
+		}
+		if display.Width > 0 && display.Height > 0 {
+			return display.Width, display.Height
+		}
+	}
+
+	return s.Width, s.Height
+}
+
+// ServerInfo retrieves the device information from GET /server-info.
+//
+// The result is also reflected to Device() and Features().
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	return c.fetchServerInfo(ctx, "server-info")
+}
+
+// Info retrieves the device information from GET /info which is supported by AirPlay 2 devices.
+//
+// The result is also reflected to Device() and Features().
+func (c *Client) Info(ctx context.Context) (*ServerInfo, error) {
+	return c.fetchServerInfo(ctx, "info")
+}
+
+// probeFeatures fetches /server-info once if the features of the device are unknown
+// but needed to choose the body format or whether /feedback is sent. Failures are ignored.
+func (c *Client) probeFeatures(ctx context.Context) {
+	if c.bodyFormat != BodyFormatAuto && c.feedbackInterval != 0 {
+		return
+	}
+
+	c.mu.Lock()
+	probed := c.featuresProbed
+	c.featuresProbed = true
+	c.mu.Unlock()
+
+	if probed || c.Features() != 0 {
+		return
+	}
+	c.fetchServerInfo(ctx, "server-info")
+}
+
+func (c *Client) fetchServerInfo(ctx context.Context, path string) (*ServerInfo, error) {
+	response, err := c.connection.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ServerInfo{}
+	if err := unmarshalPlist(body, info); err != nil {
+		return nil, err
+	}
+
+	aliases := &serverInfoAliases{}
+	if err := unmarshalPlist(body, aliases); err != nil {
+		return nil, err
+	}
+	if info.DeviceID == "" {
+		info.DeviceID = aliases.DeviceID
+	}
+	if info.SourceVersion == "" {
+		info.SourceVersion = aliases.SourceVersion
+	}
+	if info.ProtocolVersion == "" {
+		info.ProtocolVersion = aliases.ProtocolVersion
+	}
+
+	c.mu.Lock()
+	c.serverInfo = info
+	c.mu.Unlock()
+
+	return info, nil
+}
+
+// Device returns the AirPlay device of the client.
+//
+// Extra is complemented by the result of ServerInfo() or Info() if it has been retrieved.
+func (c *Client) Device() Device {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	device := c.connection.device
+	if info := c.serverInfo; info != nil {
+		extra := &device.Extra
+		if extra.Model == "" {
+			extra.Model = info.Model
+		}
+		if extra.Features == "" && info.Features != 0 {
+			extra.Features = info.Features.String()
+		}
+		if extra.MacAddress == "" {
+			extra.MacAddress = info.MacAddress
+		}
+		if extra.MacAddress == "" {
+			extra.MacAddress = info.DeviceID
+		}
+		if extra.ServerVersion == "" {
+			extra.ServerVersion = info.SourceVersion
+		}
+		if device.Name == "" {
+			device.Name = info.Name
+		}
+	}
+
+	return device
+}
+
+// Features returns the features supported by the device.
+//
+// It is known from the mDNS TXT record when the client is created by discovery,
+// otherwise after ServerInfo() or Info() is called. 0 means unknown.
+func (c *Client) Features() Features {
+	c.mu.Lock()
+	info := c.serverInfo
+	c.mu.Unlock()
+
+	if info != nil && info.Features != 0 {
+		return info.Features
+	}
+
+	features, _ := ParseFeatures(c.connection.device.Extra.Features)
+	return features
+}
@@ -0,0 +1,199 @@
+package airplay
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+const serverInfoXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>deviceid</key>
+	<string>58:55:CA:1A:E2:88</string>
+	<key>features</key>
+	<integer>14839</integer>
+	<key>macAddress</key>
+	<string>58:55:CA:1A:E2:88</string>
+	<key>model</key>
+	<string>AppleTV2,1</string>
+	<key>osBuildVersion</key>
+	<string>12A365b</string>
+	<key>protovers</key>
+	<string>1.0</string>
+	<key>srcvers</key>
+	<string>120.2</string>
+	<key>vv</key>
+	<integer>1</integer>
+	<key>width</key>
+	<integer>1280</integer>
+	<key>height</key>
+	<integer>720</integer>
+</dict>
+</plist>`
+
+func TestParseFeatures(t *testing.T) {
+	features, err := ParseFeatures("0x5A7FFFF7,0x1E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if features != Features(0x1E5A7FFFF7) {
+		t.Fatalf("Incorrect features (actual %s)", features)
+	}
+
+	if !features.Has(FeatureVideo|FeaturePhoto) || !features.Has(FeatureVideoPlayQueue) {
+		t.Fatalf("Features should have video, photo and play queue (actual %s)", features)
+	}
+
+	if features.String() != "0x5A7FFFF7,0x1E" {
+		t.Fatalf("Incorrect string of features (actual %s)", features.String())
+	}
+
+	if _, err := ParseFeatures("gongo"); err == nil {
+		t.Fatal("It should occurs [invalid features] error")
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/server-info"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/x-apple-plist+xml")
+		w.Write([]byte(serverInfoXML))
+	})
+
+	client := getTestClient(t, ts)
+	info, err := client.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.DeviceID != "58:55:CA:1A:E2:88" || info.Model != "AppleTV2,1" {
+		t.Errorf("Incorrect device id or model (actual %s, %s)", info.DeviceID, info.Model)
+	}
+
+	if info.Features != Features(14839) || info.ProtocolVersion != "1.0" || info.SourceVersion != "120.2" || info.VV != 1 {
+		t.Errorf("Incorrect server info (actual %+v)", info)
+	}
+
+	if width, height := info.DisplaySize(); width != 1280 || height != 720 {
+		t.Errorf("Incorrect display size (actual %dx%d)", width, height)
+	}
+
+	device := client.Device()
+	if device.Extra.Model != "AppleTV2,1" || device.Extra.ServerVersion != "120.2" || device.Extra.MacAddress != "58:55:CA:1A:E2:88" {
+		t.Errorf("Device extra should be complemented (actual %+v)", device.Extra)
+	}
+
+	if !client.Features().Has(FeatureVideo | FeaturePhoto) {
+		t.Errorf("Incorrect features of client (actual %s)", client.Features())
+	}
+}
+
+func TestInfoWithBinaryPlist(t *testing.T) {
+	body, err := plist.Marshal(map[string]interface{}{
+		"deviceID":      "AA:BB:CC:DD:EE:FF",
+		"features":      uint64(0x1C340405FCA00),
+		"model":         "AppleTV6,2",
+		"name":          "Living Room",
+		"sourceVersion": "550.10",
+		"vv":            uint64(2),
+		"pk":            []byte{0x01, 0x02, 0x03},
+		"audioFormats": []interface{}{
+			map[string]interface{}{"type": uint64(100), "audioInputFormats": uint64(67108860), "audioOutputFormats": uint64(67108860)},
+		},
+		"displays": []interface{}{
+			map[string]interface{}{"uuid": "display-1", "widthPixels": uint64(3840), "heightPixels": uint64(2160), "refreshRate": 60.0},
+		},
+	}, plist.BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/info"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/x-apple-binary-plist")
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+	info, err := client.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.DeviceID != "AA:BB:CC:DD:EE:FF" || info.SourceVersion != "550.10" || info.Name != "Living Room" {
+		t.Errorf("Incorrect server info (actual %+v)", info)
+	}
+
+	if !info.Features.Has(FeatureUnifiedMediaControl) {
+		t.Errorf("Incorrect features (actual %s)", info.Features)
+	}
+
+	if len(info.AudioFormats) != 1 || info.AudioFormats[0].Type != 100 || info.AudioFormats[0].OutputFormats != 67108860 {
+		t.Errorf("Incorrect audio formats (actual %+v)", info.AudioFormats)
+	}
+
+	if width, height := info.DisplaySize(); width != 3840 || height != 2160 {
+		t.Errorf("Incorrect display size (actual %dx%d)", width, height)
+	}
+
+	if len(info.PublicKey) != 3 {
+		t.Errorf("Incorrect pk (actual %v)", info.PublicKey)
+	}
+
+	if client.Device().Name != "Living Room" {
+		t.Errorf("Device name should be complemented (actual %s)", client.Device().Name)
+	}
+}
+
+func TestPlayProbesFeatures(t *testing.T) {
+	expectRequests := []testExpectRequest{
+		{"GET", "/server-info"},
+		{"POST", "/play"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+		{"POST", "/play"},
+		{"GET", "/playback-info"},
+		{"GET", "/playback-info"},
+	}
+	responseXMLs := []string{
+		playingPlaybackInfo,
+		stopPlaybackInfo,
+		playingPlaybackInfo,
+		stopPlaybackInfo,
+	}
+
+	ts := airTestServer(t, expectRequests, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/server-info":
+			body, _ := plist.Marshal(map[string]interface{}{
+				"features": uint64(FeatureVideo | FeatureUnifiedMediaControl),
+			}, plist.BinaryFormat)
+			w.Write(body)
+		case "/play":
+			if req.Header.Get("Content-Type") != "application/x-apple-binary-plist" {
+				t.Fatalf("Body format should be chosen by probed features (actual %s)", req.Header.Get("Content-Type"))
+			}
+		case "/playback-info":
+			w.Write([]byte(responseXMLs[0]))
+			responseXMLs = responseXMLs[1:]
+		}
+	})
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, _ := NewClient(&ClientParam{Addr: addr, Port: port, PollInterval: testPollInterval, FeedbackInterval: time.Hour})
+
+	// /server-info is fetched only before the first playback.
+	for i := 0; i < 2; i++ {
+		if err := <-client.Play("http://movie.example.com/go.mp4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !client.Features().Has(FeatureUnifiedMediaControl) {
+		t.Fatalf("Features should be probed (actual %s)", client.Features())
+	}
+}
@@ -0,0 +1,169 @@
+package airplay
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionUserAgent = "MediaControl/1.0"
+
+	// defaultFeedbackInterval is the interval of /feedback required by tvOS to keep the session.
+	defaultFeedbackInterval = 2 * time.Second
+)
+
+// A session is an AirPlay session with the device.
+//
+// It owns one persistent keep-alive connection to the device,
+// and every request in the session is stamped with the session headers.
+type session struct {
+	id       string
+	deviceID string
+	client   *http.Client
+
+	mu             sync.Mutex
+	stopFeedbackCh chan struct{}
+
+	// feedbackRefs is the number of startFeedback() not yet stopped.
+	feedbackRefs int
+}
+
+// defaultConnectTimeout is the timeout of dialing the device.
+const defaultConnectTimeout = 30 * time.Second
+
+func newSession(client *http.Client) *session {
+	return &session{
+		id:       newUUID(),
+		deviceID: localDeviceID(),
+		client:   client,
+	}
+}
+
+// newDialer returns the dialer to the device.
+// If timeout is 0, defaultConnectTimeout is used.
+func newDialer(timeout time.Duration) *net.Dialer {
+	if timeout == 0 {
+		timeout = defaultConnectTimeout
+	}
+
+	return &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// newHTTPClient returns the client of a session that sends requests with transport.
+//
+// If transport is nil, the client keeps one persistent connection dialed by dialer.
+func newHTTPClient(transport http.RoundTripper, dialer *net.Dialer) *http.Client {
+	if transport == nil {
+		transport = &http.Transport{
+			DialContext:         dialer.DialContext,
+			MaxConnsPerHost:     1,
+			MaxIdleConnsPerHost: 1,
+			DisableCompression:  true,
+		}
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// stamp sets the session headers to header.
+func (s *session) stamp(header http.Header) {
+	header.Set("User-Agent", sessionUserAgent)
+	header.Set("X-Apple-Session-ID", s.id)
+	header.Set("X-Apple-Device-ID", s.deviceID)
+}
+
+// startFeedback calls send every interval until stopFeedback is called
+// as many times as startFeedback.
+// If feedback is already running, it is shared with the running one.
+func (s *session) startFeedback(interval time.Duration, send func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feedbackRefs++
+	if s.stopFeedbackCh != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.stopFeedbackCh = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				send()
+			}
+		}
+	}()
+}
+
+// stopFeedback releases one startFeedback(), and stops feedback when none is left.
+func (s *session) stopFeedback() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.feedbackRefs > 0 {
+		s.feedbackRefs--
+	}
+	if s.feedbackRefs == 0 {
+		s.closeFeedback()
+	}
+}
+
+// closeFeedback stops feedback. s.mu must be held.
+func (s *session) closeFeedback() {
+	if s.stopFeedbackCh != nil {
+		close(s.stopFeedbackCh)
+		s.stopFeedbackCh = nil
+	}
+}
+
+// close ends the session and closes the persistent connection.
+func (s *session) close() {
+	s.mu.Lock()
+	s.feedbackRefs = 0
+	s.closeFeedback()
+	s.mu.Unlock()
+	if transport, ok := s.client.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// newUUID returns a random UUID used as X-Apple-Session-ID and so on.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// localDeviceID returns X-Apple-Device-ID derived from the hardware address of this host.
+//
+// If no hardware address is found, a random one is used.
+func localDeviceID() string {
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 && len(iface.HardwareAddr) == 6 {
+			return fmt.Sprintf("0x%x", []byte(iface.HardwareAddr))
+		}
+	}
+
+	b := make([]byte, 6)
+	rand.Read(b)
+	b[0] = (b[0] | 0x02) & 0xfe // locally administered, unicast
+	return fmt.Sprintf("0x%x", b)
+}
@@ -0,0 +1,272 @@
+package airplay
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionHeadersAndPersistentConnection(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	sessionIDs := map[string]bool{}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("User-Agent") != "MediaControl/1.0" {
+			t.Errorf("Incorrect User-Agent (actual %s)", req.Header.Get("User-Agent"))
+		}
+
+		if req.Header.Get("X-Apple-Device-ID") == "" {
+			t.Error("Not found X-Apple-Device-ID header")
+		}
+
+		mu.Lock()
+		sessionIDs[req.Header.Get("X-Apple-Session-ID")] = true
+		mu.Unlock()
+	}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	client := getTestClient(t, ts)
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := client.Rate(1.0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if connections != 1 {
+		t.Errorf("Requests should be sent over one connection (actual %d)", connections)
+	}
+
+	if len(sessionIDs) != 1 || sessionIDs[""] {
+		t.Errorf("Requests should have the same X-Apple-Session-ID (actual %v)", sessionIDs)
+	}
+}
+
+func TestFeedbackDuringPlayback(t *testing.T) {
+	var mu sync.Mutex
+	feedbacks := 0
+	responseXMLs := []string{playingPlaybackInfo, playingPlaybackInfo, stopPlaybackInfo}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.URL.Path {
+		case "/feedback":
+			feedbacks++
+		case "/playback-info":
+			xml := responseXMLs[0]
+			if len(responseXMLs) > 1 && feedbacks > 0 {
+				responseXMLs = responseXMLs[1:]
+			}
+			w.Write([]byte(xml))
+		}
+	}))
+	defer ts.Close()
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, err := NewClient(&ClientParam{Addr: addr, Port: port, FeedbackInterval: 5 * time.Millisecond, PollInterval: testPollInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-client.Play("http://movie.example.com/go.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if feedbacks == 0 {
+		t.Fatal("POST /feedback should be sent during playback")
+	}
+}
+
+func TestFeedbackDuringOverlappingPlayback(t *testing.T) {
+	var mu sync.Mutex
+	feedbacks := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.URL.Path {
+		case "/feedback":
+			feedbacks++
+		case "/playback-info":
+			w.Write([]byte(playingPlaybackInfo))
+		}
+	}))
+	defer ts.Close()
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, err := NewClient(&ClientParam{Addr: addr, Port: port, FeedbackInterval: 5 * time.Millisecond, PollInterval: testPollInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := client.PlayWithResult(ctx, "http://movie.example.com/1.mp4", nil)
+	time.Sleep(20 * time.Millisecond)
+	second := client.PlayWithResult(ctx, "http://movie.example.com/2.mp4", nil)
+
+	select {
+	case result := <-first:
+		if result.Reason != PlaybackPreempted {
+			t.Fatalf("First playback should be preempted (actual %v)", result.Reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("First playback was not preempted")
+	}
+
+	mu.Lock()
+	before := feedbacks
+	mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	after := feedbacks
+	mu.Unlock()
+
+	if after <= before {
+		t.Fatalf("POST /feedback should keep being sent after the first playback ends (before %d, after %d)", before, after)
+	}
+
+	cancel()
+	<-second
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClientWithTransport(t *testing.T) {
+	requests := []string{}
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.Method+" "+req.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Header:     http.Header{},
+			Request:    req,
+		}, nil
+	})
+
+	client, err := NewClient(&ClientParam{Addr: "192.0.2.1", Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 1 || requests[0] != "POST http://192.0.2.1:7000/stop" {
+		t.Fatalf("Request should be sent by the transport (actual %v)", requests)
+	}
+}
+
+func TestEventsWithTransportSkipsReverse(t *testing.T) {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/playback-info" {
+			t.Errorf("Unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(playingPlaybackInfo)),
+			Header:     http.Header{},
+			Request:    req,
+		}, nil
+	})
+
+	// 192.0.2.1 is unroutable, so dialing it directly would block.
+	client, err := NewClient(&ClientParam{Addr: "192.0.2.1", Transport: transport, PollInterval: testPollInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	select {
+	case event := <-client.Events(ctx):
+		if event.State != StateLoading && event.State != StatePlaying {
+			t.Fatalf("Incorrect polled event (actual %+v)", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Events should fall back to polling without the reverse connection")
+	}
+}
+
+func TestClientWithRequestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, err := NewClient(&ClientParam{Addr: addr, Port: port, RequestTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Stop()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("It should occurs timeout error (actual %v)", err)
+	}
+
+	var deviceErr *DeviceError
+	if !errors.As(err, &deviceErr) {
+		t.Fatalf("It should occurs *DeviceError (actual %v)", err)
+	}
+}
+
+func TestClientWithReadyTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/playback-info" {
+			w.Write([]byte(stopPlaybackInfo))
+		}
+	}))
+	defer ts.Close()
+
+	addr, port := getAddrAndPort(t, ts.URL)
+	client, err := NewClient(&ClientParam{Addr: addr, Port: port, ReadyTimeout: 20 * time.Millisecond, PollInterval: testPollInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-client.Play("http://movie.example.com/go.mp4"):
+		if err != ErrNotReadyTimeout {
+			t.Fatalf("It should occurs ErrNotReadyTimeout (actual %v)", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadyTimeout is not applied")
+	}
+}
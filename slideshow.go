@@ -0,0 +1,262 @@
+package airplay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+var slideshowAssetPattern = regexp.MustCompile(`^/slideshows/1/assets/(\d+)$`)
+
+// An AssetProvider returns a JPEG picture shown as the id-th slide (starting from 1).
+//
+// If it returns an error, the device is told that the asset is not found.
+type AssetProvider func(ctx context.Context, id int) ([]byte, error)
+
+// A SlideshowTheme is a theme of slideshow supported by the device.
+type SlideshowTheme struct {
+	Key  string `plist:"key"`
+	Name string `plist:"name"`
+}
+
+// A SlideshowEvent is a change of slideshow state notified by the device.
+type SlideshowEvent struct {
+	// State represents the new state of slideshow.
+	State PlaybackState `plist:"state"`
+
+	// LastAssetID represents the id of asset shown lastly.
+	LastAssetID int `plist:"lastAssetID"`
+}
+
+type SlideshowParam struct {
+	// Theme is the key of SlideshowTheme. If empty, "Classic" is used.
+	Theme string
+
+	// SlideDuration is the duration to show a slide. If zero, 3 seconds.
+	//
+	// The device accepts whole seconds, so it is rounded to the nearest second,
+	// and a value under 1 second is regarded as 1 second.
+	SlideDuration time.Duration
+
+	// Assets provides the pictures requested by the device.
+	Assets AssetProvider
+}
+
+// A Slideshow is a slideshow driven by the device.
+//
+// The device requests each next picture over the reverse connection,
+// and the pictures are provided by SlideshowParam.Assets.
+type Slideshow struct {
+	client *Client
+	params SlideshowParam
+	events chan SlideshowEvent
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+}
+
+type slideshowSettings struct {
+	SlideDuration int    `plist:"slideDuration"`
+	Theme         string `plist:"theme"`
+}
+
+type slideshowRequest struct {
+	Settings *slideshowSettings `plist:"settings,omitempty"`
+	State    PlaybackState      `plist:"state"`
+}
+
+type slideshowAsset struct {
+	Data []byte `plist:"data"`
+	Info struct {
+		ID  int `plist:"id"`
+		Key int `plist:"key"`
+	} `plist:"info"`
+}
+
+type slideshowFeatures struct {
+	Themes []SlideshowTheme `plist:"themes"`
+}
+
+// SlideshowFeatures retrieves the themes of slideshow supported by the device.
+func (c *Client) SlideshowFeatures(ctx context.Context) ([]SlideshowTheme, error) {
+	response, err := c.connection.get(ctx, "slideshow-features")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	features := &slideshowFeatures{}
+	if err := decodeBody(response.Header.Get("Content-Type"), body, features); err != nil {
+		return nil, err
+	}
+
+	return features.Themes, nil
+}
+
+// NewSlideshow returns a slideshow on the device of client.
+func NewSlideshow(client *Client, params *SlideshowParam) (*Slideshow, error) {
+	if params.Assets == nil {
+		return nil, errors.New("airplay: [ERR] Assets is required to NewSlideshow()")
+	}
+
+	s := &Slideshow{
+		client: client,
+		params: *params,
+		events: make(chan SlideshowEvent, 8),
+	}
+
+	if s.params.Theme == "" {
+		s.params.Theme = "Classic"
+	}
+
+	switch {
+	case s.params.SlideDuration <= 0:
+		s.params.SlideDuration = 3 * time.Second
+	case s.params.SlideDuration < time.Second:
+		s.params.SlideDuration = time.Second
+	default:
+		s.params.SlideDuration = s.params.SlideDuration.Round(time.Second)
+	}
+
+	return s, nil
+}
+
+// Start starts the slideshow.
+//
+// The slideshow continues until Stop() is called or ctx is done.
+// A Slideshow can be started only once.
+//
+// It fails with ErrReverseUnavailable if the client has HTTPClient or Transport without Dialer,
+// because the device requests pictures over the reverse connection.
+func (s *Slideshow) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return errors.New("airplay: [ERR] Slideshow is already started")
+	}
+	s.started = true
+
+	ctx, cancel := context.WithCancel(ctx)
+	reverse, err := s.client.connection.openReverse(ctx, reversePurposeSlideshow)
+	if err != nil {
+		cancel()
+		close(s.events)
+		return err
+	}
+
+	go func() {
+		defer close(s.events)
+		reverse.serve(func(req *http.Request) *http.Response {
+			return s.handle(ctx, req)
+		})
+	}()
+
+	settings := &slideshowSettings{
+		SlideDuration: int(s.params.SlideDuration / time.Second),
+		Theme:         s.params.Theme,
+	}
+	if err := s.put(ctx, &slideshowRequest{Settings: settings, State: StatePlaying}); err != nil {
+		cancel()
+		return err
+	}
+
+	s.cancel = cancel
+	return nil
+}
+
+// Stop stops the slideshow.
+func (s *Slideshow) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel == nil {
+		return nil
+	}
+
+	err := s.put(ctx, &slideshowRequest{State: StateStopped})
+	s.cancel()
+	s.cancel = nil
+
+	return err
+}
+
+// Events returns the channel that receives changes of slideshow state.
+//
+// It is closed when the slideshow is stopped.
+func (s *Slideshow) Events() <-chan SlideshowEvent {
+	return s.events
+}
+
+func (s *Slideshow) put(ctx context.Context, request *slideshowRequest) error {
+	body, err := plist.Marshal(request, plist.XMLFormat)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{
+		"Content-Type": {contentTypeXMLPlist},
+	}
+	response, err := s.client.connection.putWithHeader(ctx, "slideshows/1", bytes.NewReader(body), header)
+	if err != nil {
+		return err
+	}
+	discardBody(response)
+
+	return nil
+}
+
+// handle responds to a request from the device over the reverse connection.
+func (s *Slideshow) handle(ctx context.Context, req *http.Request) *http.Response {
+	if req.Method == "POST" && req.URL.Path == "/event" {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil
+		}
+
+		event := &SlideshowEvent{}
+		if err := unmarshalPlist(body, event); err == nil {
+			select {
+			case s.events <- *event:
+			default: // Drop events if nobody is receiving.
+			}
+		}
+		return nil
+	}
+
+	matches := slideshowAssetPattern.FindStringSubmatch(req.URL.Path)
+	if req.Method != "GET" || matches == nil {
+		return newReverseResponse(http.StatusNotFound, "", nil)
+	}
+
+	id, _ := strconv.Atoi(matches[1])
+	data, err := s.params.Assets(ctx, id)
+	if err != nil {
+		return newReverseResponse(http.StatusNotFound, "", nil)
+	}
+
+	asset := &slideshowAsset{Data: data}
+	asset.Info.ID = id
+	asset.Info.Key = id
+
+	body, err := plist.Marshal(asset, plist.BinaryFormat)
+	if err != nil {
+		return newReverseResponse(http.StatusInternalServerError, "", nil)
+	}
+
+	return newReverseResponse(http.StatusOK, contentTypeBinaryPlist, body)
+}
@@ -0,0 +1,188 @@
+package airplay
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DHowett/go-plist"
+)
+
+func TestSlideshowFeatures(t *testing.T) {
+	body, _ := plist.Marshal(map[string]interface{}{
+		"themes": []interface{}{
+			map[string]interface{}{"key": "Classic", "name": "Classic"},
+			map[string]interface{}{"key": "KenBurns", "name": "Ken Burns"},
+		},
+	}, plist.XMLFormat)
+
+	ts := airTestServer(t, []testExpectRequest{{"GET", "/slideshow-features"}}, func(t *testing.T, w http.ResponseWriter, req *http.Request) {
+		w.Write(body)
+	})
+
+	client := getTestClient(t, ts)
+	themes, err := client.SlideshowFeatures(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(themes) != 2 || themes[1].Key != "KenBurns" || themes[1].Name != "Ken Burns" {
+		t.Fatalf("Incorrect themes (actual %+v)", themes)
+	}
+}
+
+func TestSlideshow(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/slideshows/1":
+			body, _ := ioutil.ReadAll(req.Body)
+			params := &slideshowRequest{}
+			if _, err := plist.Unmarshal(body, params); err != nil {
+				t.Error(err)
+			}
+
+			switch params.State {
+			case StatePlaying:
+				if params.Settings == nil || params.Settings.Theme != "KenBurns" || params.Settings.SlideDuration != 5 {
+					t.Errorf("Incorrect slideshow settings (actual %+v)", params.Settings)
+				}
+				close(started)
+			case StateStopped:
+				<-finished
+			}
+		case "/reverse":
+			if req.Header.Get("X-Apple-Purpose") != "slideshow" {
+				t.Errorf("Incorrect X-Apple-Purpose (actual %s)", req.Header.Get("X-Apple-Purpose"))
+			}
+
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			defer close(finished)
+
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: PTTH/1.0\r\nConnection: Upgrade\r\n\r\n")
+			rw.Flush()
+			<-started
+
+			reader := bufio.NewReader(conn)
+			for id, expect := range []int{http.StatusOK, http.StatusOK, http.StatusNotFound} {
+				fmt.Fprintf(conn, "GET /slideshows/1/assets/%d HTTP/1.1\r\nAccept: application/x-apple-binary-plist\r\n\r\n", id+1)
+
+				response, err := http.ReadResponse(reader, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				body, _ := ioutil.ReadAll(response.Body)
+
+				if response.StatusCode != expect {
+					t.Errorf("Incorrect response status (actual %d)", response.StatusCode)
+					continue
+				}
+
+				if response.StatusCode == http.StatusOK {
+					asset := &slideshowAsset{}
+					if _, err := plist.Unmarshal(body, asset); err != nil {
+						t.Error(err)
+					}
+
+					if string(asset.Data) != fmt.Sprintf("picture-%d", id+1) || asset.Info.ID != id+1 {
+						t.Errorf("Incorrect asset (actual %s, %d)", asset.Data, asset.Info.ID)
+					}
+				}
+			}
+
+			event := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>category</key><string>slideshow</string><key>lastAssetID</key><integer>2</integer><key>state</key><string>stopped</string></dict></plist>`
+			fmt.Fprintf(conn, "POST /event HTTP/1.1\r\nContent-Length: %d\r\n\r\n%s", len(event), event)
+			http.ReadResponse(reader, nil)
+		}
+	}))
+	defer ts.Close()
+
+	client := getTestClient(t, ts)
+	slideshow, err := NewSlideshow(client, &SlideshowParam{
+		Theme:         "KenBurns",
+		SlideDuration: 5 * time.Second,
+		Assets: func(ctx context.Context, id int) ([]byte, error) {
+			if id > 2 {
+				return nil, errors.New("no more pictures")
+			}
+			return []byte(fmt.Sprintf("picture-%d", id)), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := slideshow.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	event := <-slideshow.Events()
+	if event.State != StateStopped || event.LastAssetID != 2 {
+		t.Fatalf("Incorrect slideshow event (actual %+v)", event)
+	}
+
+	if err := slideshow.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewSlideshowWithoutAssets(t *testing.T) {
+	client, _ := NewClient(&ClientParam{Addr: "192.0.2.1"})
+	if _, err := NewSlideshow(client, &SlideshowParam{}); err == nil {
+		t.Fatal("It should occurs [Assets is required] error")
+	}
+}
+
+func TestNewSlideshowRoundsSlideDuration(t *testing.T) {
+	client, _ := NewClient(&ClientParam{Addr: "192.0.2.1"})
+	assets := func(ctx context.Context, id int) ([]byte, error) { return nil, nil }
+
+	tests := []struct {
+		duration time.Duration
+		expect   time.Duration
+	}{
+		{0, 3 * time.Second},
+		{300 * time.Millisecond, time.Second},
+		{2500 * time.Millisecond, 3 * time.Second},
+		{4200 * time.Millisecond, 4 * time.Second},
+	}
+
+	for _, test := range tests {
+		s, err := NewSlideshow(client, &SlideshowParam{SlideDuration: test.duration, Assets: assets})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.params.SlideDuration != test.expect {
+			t.Fatalf("Incorrect slide duration of %v (expected %v, actual %v)", test.duration, test.expect, s.params.SlideDuration)
+		}
+	}
+}
+
+func TestSlideshowWithTransport(t *testing.T) {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("Unexpected request %s %s", req.Method, req.URL.Path)
+		return nil, errors.New("unexpected request")
+	})
+	client, _ := NewClient(&ClientParam{Addr: "192.0.2.1", Transport: transport})
+	assets := func(ctx context.Context, id int) ([]byte, error) { return nil, nil }
+
+	s, err := NewSlideshow(client, &SlideshowParam{Assets: assets})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(context.Background()); !errors.Is(err, ErrReverseUnavailable) {
+		t.Fatalf("It should occurs ErrReverseUnavailable (actual %v)", err)
+	}
+}
@@ -0,0 +1,191 @@
+package airplay
+
+import (
+	"fmt"
+	"time"
+)
+
+// WatchdogEventType represents the type of WatchdogEvent.
+type WatchdogEventType int
+
+const (
+	// WatchdogBuffering means the device has consumed all buffered content.
+	WatchdogBuffering WatchdogEventType = iota
+
+	// WatchdogStalled means the position has not advanced for WatchdogParam.StallThreshold.
+	WatchdogStalled
+
+	// WatchdogRecovered means the position advances again after buffering or stalled.
+	WatchdogRecovered
+
+	// WatchdogRestarted means content was played again at the last position by the recovery policy.
+	WatchdogRestarted
+)
+
+func (t WatchdogEventType) String() string {
+	switch t {
+	case WatchdogBuffering:
+		return "buffering"
+	case WatchdogStalled:
+		return "stalled"
+	case WatchdogRecovered:
+		return "recovered"
+	case WatchdogRestarted:
+		return "restarted"
+	}
+	return fmt.Sprintf("WatchdogEventType(%d)", int(t))
+}
+
+// A WatchdogEvent is a change of the playback health.
+type WatchdogEvent struct {
+	Type WatchdogEventType
+
+	// Position represents the last position (second).
+	Position float64
+
+	// Elapsed is how long playback has not advanced.
+	Elapsed time.Duration
+}
+
+// WatchdogParam are the thresholds of Watchdog.
+type WatchdogParam struct {
+	// BufferingThreshold is how long the buffer must be empty to be buffering. If zero, immediately.
+	BufferingThreshold time.Duration
+
+	// StallThreshold is how long the position must not advance to be stalled. If zero, 5 seconds.
+	StallThreshold time.Duration
+
+	// RestartAfter, if positive, plays content again at the last position
+	// when playback has been stalled for this duration.
+	RestartAfter time.Duration
+
+	// MaxRestarts is the number of restarts in a playback. If zero, 3.
+	MaxRestarts int
+}
+
+type watchdogState int
+
+const (
+	watchdogHealthy watchdogState = iota
+	watchdogBuffering
+	watchdogStalled
+)
+
+// A Watchdog watches the playback health through PlaybackOptions.Watchdog.
+//
+// It raises events based on the buffer flags of playback informations
+// and whether the position is advancing. A Watchdog watches one playback at a time.
+type Watchdog struct {
+	params WatchdogParam
+	events chan WatchdogEvent
+
+	state        watchdogState
+	lastPosition float64
+	emptySince   time.Time
+	stuckSince   time.Time
+	stalledAt    time.Time
+	restarts     int
+}
+
+// NewWatchdog returns a watchdog with params. If params is nil, the defaults are used.
+func NewWatchdog(params *WatchdogParam) *Watchdog {
+	w := &Watchdog{
+		events: make(chan WatchdogEvent, 8),
+	}
+
+	if params != nil {
+		w.params = *params
+	}
+
+	if w.params.StallThreshold <= 0 {
+		w.params.StallThreshold = 5 * time.Second
+	}
+
+	if w.params.MaxRestarts <= 0 {
+		w.params.MaxRestarts = 3
+	}
+
+	return w
+}
+
+// Events returns the channel that receives changes of the playback health.
+//
+// Events are dropped if the channel is not received in time.
+func (w *Watchdog) Events() <-chan WatchdogEvent {
+	return w.events
+}
+
+// reset starts watching a new playback.
+func (w *Watchdog) reset() {
+	w.state = watchdogHealthy
+	w.lastPosition = -1
+	w.emptySince = time.Time{}
+	w.stuckSince = time.Time{}
+	w.restarts = 0
+}
+
+// observe updates the health by info polled at now,
+// and reports whether content should be played again.
+func (w *Watchdog) observe(info *PlaybackInfo, now time.Time) bool {
+	advancing := w.lastPosition >= 0 && info.Position != w.lastPosition
+	w.lastPosition = info.Position
+
+	if info.PlaybackBufferEmpty {
+		if w.emptySince.IsZero() {
+			w.emptySince = now
+		}
+	} else {
+		w.emptySince = time.Time{}
+	}
+
+	// The position is expected to advance unless paused.
+	if advancing || (info.Rate == 0 && !info.PlaybackBufferEmpty) {
+		w.stuckSince = time.Time{}
+	} else if w.stuckSince.IsZero() {
+		w.stuckSince = now
+	}
+
+	if advancing && !info.PlaybackBufferEmpty {
+		if w.state != watchdogHealthy {
+			w.state = watchdogHealthy
+			w.emit(WatchdogRecovered, info.Position, 0)
+		}
+		return false
+	}
+
+	var elapsed time.Duration
+	if !w.stuckSince.IsZero() {
+		elapsed = now.Sub(w.stuckSince)
+	}
+
+	switch w.state {
+	case watchdogHealthy:
+		if !w.emptySince.IsZero() && now.Sub(w.emptySince) >= w.params.BufferingThreshold {
+			w.state = watchdogBuffering
+			w.emit(WatchdogBuffering, info.Position, elapsed)
+		}
+		fallthrough
+	case watchdogBuffering:
+		if !w.stuckSince.IsZero() && elapsed >= w.params.StallThreshold {
+			w.state = watchdogStalled
+			w.stalledAt = now
+			w.emit(WatchdogStalled, info.Position, elapsed)
+		}
+	case watchdogStalled:
+		if w.params.RestartAfter > 0 && w.restarts < w.params.MaxRestarts && now.Sub(w.stalledAt) >= w.params.RestartAfter {
+			w.restarts++
+			w.stalledAt = now
+			w.emit(WatchdogRestarted, info.Position, elapsed)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Watchdog) emit(t WatchdogEventType, position float64, elapsed time.Duration) {
+	select {
+	case w.events <- WatchdogEvent{Type: t, Position: position, Elapsed: elapsed}:
+	default:
+	}
+}
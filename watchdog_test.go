@@ -0,0 +1,129 @@
+package airplay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DHowett/go-plist"
+	"github.com/gongo/text-parameters"
+)
+
+func TestWatchdogObserve(t *testing.T) {
+	w := NewWatchdog(&WatchdogParam{
+		BufferingThreshold: time.Second,
+		StallThreshold:     3 * time.Second,
+		RestartAfter:       2 * time.Second,
+		MaxRestarts:        1,
+	})
+	w.reset()
+
+	start := time.Now()
+	tests := []struct {
+		at      time.Duration
+		info    PlaybackInfo
+		restart bool
+		event   *WatchdogEventType
+	}{
+		{0, PlaybackInfo{Position: 10, Rate: 1}, false, nil},
+		{1, PlaybackInfo{Position: 11, Rate: 1}, false, nil},
+		{2, PlaybackInfo{Position: 11, Rate: 1, PlaybackBufferEmpty: true}, false, nil},
+		{3, PlaybackInfo{Position: 11, Rate: 1, PlaybackBufferEmpty: true}, false, eventTypeOf(WatchdogBuffering)},
+		{5, PlaybackInfo{Position: 11, Rate: 1, PlaybackBufferEmpty: true}, false, eventTypeOf(WatchdogStalled)},
+		{6, PlaybackInfo{Position: 11, Rate: 1, PlaybackBufferEmpty: true}, false, nil},
+		{7, PlaybackInfo{Position: 11, Rate: 1, PlaybackBufferEmpty: true}, true, eventTypeOf(WatchdogRestarted)},
+		{10, PlaybackInfo{Position: 11, Rate: 1, PlaybackBufferEmpty: true}, false, nil}, // MaxRestarts
+		{11, PlaybackInfo{Position: 12, Rate: 1}, false, eventTypeOf(WatchdogRecovered)},
+		{20, PlaybackInfo{Position: 12, Rate: 0}, false, nil}, // paused
+		{30, PlaybackInfo{Position: 12, Rate: 0}, false, nil},
+	}
+
+	for _, test := range tests {
+		info := test.info
+		if restart := w.observe(&info, start.Add(test.at*time.Second)); restart != test.restart {
+			t.Fatalf("Incorrect restart at %d (actual %v)", test.at, restart)
+		}
+
+		select {
+		case event := <-w.Events():
+			if test.event == nil || event.Type != *test.event {
+				t.Fatalf("Unexpected event at %d (actual %s)", test.at, event.Type)
+			}
+		default:
+			if test.event != nil {
+				t.Fatalf("Not found %s event at %d", *test.event, test.at)
+			}
+		}
+	}
+}
+
+func eventTypeOf(t WatchdogEventType) *WatchdogEventType {
+	return &t
+}
+
+func TestPlayWithWatchdogRestart(t *testing.T) {
+	var mu sync.Mutex
+	plays := []float64{}
+	position := 0.0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.URL.Path {
+		case "/play":
+			u := &playbackInfoParam{}
+			parameters.NewDecorder(req.Body).Decode(u)
+			plays = append(plays, u.Seconds)
+			position = 42.0
+		case "/playback-info":
+			info := map[string]interface{}{"readyToPlay": true, "duration": 100.0, "position": position, "rate": 1.0}
+			if len(plays) == 1 {
+				info["playbackBufferEmpty"] = true
+			} else {
+				// Advances after restart, and ends.
+				position += 1
+				if position > 45 {
+					info = map[string]interface{}{"readyToPlay": false}
+				}
+			}
+
+			body, _ := plist.Marshal(info, plist.XMLFormat)
+			w.Write(body)
+		}
+	}))
+	defer ts.Close()
+
+	watchdog := NewWatchdog(&WatchdogParam{
+		StallThreshold: 5 * time.Millisecond,
+		RestartAfter:   5 * time.Millisecond,
+	})
+
+	client := getTestClient(t, ts)
+	ch := client.PlayWithResult(context.Background(), "http://movie.example.com/go.mp4", &PlaybackOptions{Watchdog: watchdog})
+
+	select {
+	case result := <-ch:
+		if result.Reason != PlaybackStoppedByReceiver || result.Err != nil {
+			t.Fatalf("Incorrect result (actual %s, %v)", result.Reason, result.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Playback was not restarted")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(plays) != 2 || plays[1] != 42.0 {
+		t.Fatalf("Content should be played again at the last position (actual %v)", plays)
+	}
+
+	expects := []WatchdogEventType{WatchdogBuffering, WatchdogStalled, WatchdogRestarted, WatchdogRecovered}
+	for _, expect := range expects {
+		if event := <-watchdog.Events(); event.Type != expect {
+			t.Fatalf("Incorrect event (expected %s, actual %s)", expect, event.Type)
+		}
+	}
+}